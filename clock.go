@@ -0,0 +1,146 @@
+package gyro
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+const (
+	// Final stretch of a frame's sleep that is spin-waited instead,
+	// since time.Sleep can overshoot by about as much on most platforms
+	SPIN_THRESHOLD = time.Millisecond
+)
+
+// Ways the default clock waits out the rest of a frame
+const (
+	// Sleep for most of the wait and spin-wait the last spin threshold,
+	// tight pacing for a little CPU. The default.
+	SLEEP_HYBRID SleepStrategy = iota
+
+	// Sleep for the whole wait with time.Sleep, the lightest on the CPU, but
+	// it can overshoot by the OS timer granularity, up to 15ms on Windows
+	SLEEP_OS
+
+	// Spin-wait for the whole wait, the most precise at the cost of a busy core
+	SLEEP_BUSY_WAIT
+)
+
+type SleepStrategy int
+
+// Clock is the time source a loop uses to measure and pace its frames
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	Sleep(d time.Duration)
+}
+
+// realClock is the default Clock, backed by the time package
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Since(t time.Time) time.Duration {
+	return time.Since(t)
+}
+
+// Sleep blocks for d, sleeping for most of it and
+// spin-waiting the last SPIN_THRESHOLD for precision
+func (realClock) Sleep(d time.Duration) {
+	sleep(SLEEP_HYBRID, SPIN_THRESHOLD, d)
+}
+
+// FakeClock is a Clock whose time only moves when the loop sleeps or when it's
+// advanced by hand, for deterministic tests: callbacks take no time, so every
+// delta time is exactly what the loop slept. It's safe for concurrent use.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock returns a fake clock starting at the Unix epoch
+func NewFakeClock() *FakeClock {
+	return &FakeClock{now: time.Unix(0, 0)}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Sleep advances the clock by d instead of blocking
+func (c *FakeClock) Sleep(d time.Duration) {
+	c.Advance(d)
+}
+
+// Advance moves the clock forward by d, or backwards when d is negative
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// sleep blocks for d with the given strategy, spin-waiting the last spin of a hybrid sleep
+func sleep(strategy SleepStrategy, spin time.Duration, d time.Duration) {
+	deadline := time.Now().Add(d)
+	switch strategy {
+	case SLEEP_OS:
+		time.Sleep(d)
+		return
+	case SLEEP_HYBRID:
+		if d > spin {
+			time.Sleep(d - spin)
+		}
+	}
+
+	for time.Now().Before(deadline) {
+		runtime.Gosched()
+	}
+}
+
+// SetSleepStrategy sets how the default clock waits out the rest of each frame,
+// SLEEP_HYBRID by default. It has no effect with a clock set by SetClock,
+// which always paces with its own Sleep.
+func (l *Loop) SetSleepStrategy(strategy SleepStrategy) *Loop {
+	l.sleepStrategy = strategy
+	return l
+}
+
+func (l *Loop) GetSleepStrategy() SleepStrategy {
+	return l.sleepStrategy
+}
+
+// SetSpinThreshold sets how much of the wait SLEEP_HYBRID spins for instead of
+// sleeping, SPIN_THRESHOLD by default. Raise it where time.Sleep overshoots more.
+func (l *Loop) SetSpinThreshold(d time.Duration) *Loop {
+	l.spinThreshold = max(d, 0)
+	return l
+}
+
+func (l *Loop) GetSpinThreshold() time.Duration {
+	return l.spinThreshold
+}
+
+// sleep waits for d with the loop clock, after the before sleep hook
+func (l *Loop) sleep(d time.Duration) {
+	if l.onBeforeSleep != nil && d > 0 {
+		start := l.clock.Now()
+		l.onBeforeSleep(d)
+		if d -= l.clock.Since(start); d <= 0 {
+			return
+		}
+	}
+
+	if _, ok := l.clock.(realClock); ok {
+		sleep(l.sleepStrategy, l.spinThreshold, d)
+		return
+	}
+	l.clock.Sleep(d)
+}
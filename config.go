@@ -0,0 +1,257 @@
+package gyro
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+// Validate checks the loop configuration, returning every problem found joined
+// into a single error that can be matched with errors.Is. It detects:
+//   - no update function or system set (ErrNoUpdateFunc)
+//   - fixed timestep and uncapped modes combined (ErrFixedUncapped)
+//   - a max render skip without fixed timestep mode (ErrRenderSkipNotFixed)
+//   - a render function on a simulation only loop (ErrRenderSimulation)
+//   - render once per frame with render skipping or dropping (ErrRenderOnceSkip)
+//   - fps sample frames with a sample window other than the default (ErrFpsSampleConflict)
+//   - a tick source combined with uncapped mode (ErrTickUncapped) or step mode
+//     (ErrTickStepMode), or a target fps other than DEFAULT_FPS or a target fps
+//     function, which the tick source overrides (ErrTickTargetFps)
+//
+// Start runs the same checks before starting the loop.
+func (l *Loop) Validate() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.validate()
+}
+
+// Build validates the loop configuration, meant to close a chain of setters
+func (l *Loop) Build() (*Loop, error) {
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// validate does the work of Validate, mu must be held
+func (l *Loop) validate() error {
+	var errs []error
+
+	if len(l.systems) == 0 {
+		errs = append(errs, ErrNoUpdateFunc)
+	}
+
+	errs = append(errs, l.configErrors()...)
+
+	if l.simulationOnly && (len(l.layers) > 0 || l.renderAlpha != nil || l.renderFrame != nil) {
+		errs = append(errs, ErrRenderSimulation)
+	}
+
+	if l.tickSource != nil {
+		if l.isUncapped {
+			errs = append(errs, ErrTickUncapped)
+		}
+		if l.isStepMode {
+			errs = append(errs, ErrTickStepMode)
+		}
+		if l.targetFps != DEFAULT_FPS || l.targetFpsFunc != nil {
+			errs = append(errs, ErrTickTargetFps)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// configErrors checks the loop parameters that don't depend on the functions set
+func (l *Loop) configErrors() []error {
+	var errs []error
+
+	if l.fixedTimestep > 0 && l.isUncapped {
+		errs = append(errs, ErrFixedUncapped)
+	}
+
+	if l.maxRenderSkip > 0 && l.fixedTimestep == 0 {
+		errs = append(errs, ErrRenderSkipNotFixed)
+	}
+
+	if l.renderOncePerFrame && (l.maxRenderSkip > 0 || l.renderAsyncDrop) {
+		errs = append(errs, ErrRenderOnceSkip)
+	}
+
+	if l.fpsSampleFrames > 0 && l.fpsSampleWindow != DEFAULT_FPS_SAMPLE_WINDOW {
+		errs = append(errs, ErrFpsSampleConflict)
+	}
+
+	return errs
+}
+
+// LoopConfig is a copy of the settable loop parameters, see GetConfig.
+// Functions, hooks, the clock and the tick source aren't part of it.
+// It holds the histogram buckets as a slice, so compare it with reflect.DeepEqual.
+type LoopConfig struct {
+	TargetFps float64
+	RenderFps int
+	InputFps  int
+
+	// Fixed timestep mode, disabled when FixedTimestep is zero
+	FixedTimestep      time.Duration
+	MaxUpdatesPerFrame int
+	MaxRenderSkip      int
+	RenderOncePerFrame bool
+	CatchUpPolicy      CatchUpPolicy
+	Interpolation      Interpolation
+
+	MaxDeltaTime   time.Duration
+	TimeScale      float64
+	DeltaSmoothing int
+	DeltaEMA       float64
+
+	FpsSampleWindow time.Duration
+	FpsSampleFrames int
+	FpsSmoothing    float64
+	MinFrameTime    time.Duration
+	MaxFrames       int
+	WarmupFrames    int
+	WarmupDuration  time.Duration
+
+	SpiralFrames   int
+	SpiralRecovery bool
+	MaxPanics      int
+	PanicWindow    time.Duration
+
+	SleepStrategy SleepStrategy
+	SpinThreshold time.Duration
+
+	EmitFrameEvents   bool
+	FrameEventsBuffer int
+	CollectHistogram  bool
+	HistogramBuckets  []time.Duration
+
+	// Modes
+	Debug             bool
+	Uncapped          bool
+	StepMode          bool
+	InputWhilePaused  bool
+	IsolatePanics     bool
+	LockOSThread      bool
+	ConcurrentRender  bool
+	RenderAsyncDrop   bool
+	RenderFirst       bool
+	SimulationOnly    bool
+	ZeroFirstDelta    bool
+	InputOnMainThread bool
+}
+
+// GetConfig returns a snapshot of the loop configuration, e.g. to log it.
+// It holds no runtime values, see Snapshot for those.
+func (l *Loop) GetConfig() LoopConfig {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return LoopConfig{
+		TargetFps:          l.targetFps,
+		RenderFps:          l.renderFps,
+		InputFps:           l.inputFps,
+		FixedTimestep:      l.fixedTimestep,
+		MaxUpdatesPerFrame: l.maxUpdatesPerFrame,
+		MaxRenderSkip:      l.maxRenderSkip,
+		RenderOncePerFrame: l.renderOncePerFrame,
+		CatchUpPolicy:      l.catchUpPolicy,
+		Interpolation:      l.interpolation,
+		MaxDeltaTime:       l.maxDeltaTime,
+		TimeScale:          l.timeScale,
+		DeltaSmoothing:     l.deltaSmoothing,
+		DeltaEMA:           l.deltaEma,
+		FpsSampleWindow:    l.fpsSampleWindow,
+		FpsSampleFrames:    l.fpsSampleFrames,
+		FpsSmoothing:       l.fpsSmoothing,
+		MinFrameTime:       l.minFrameTime,
+		MaxFrames:          l.maxFrames,
+		WarmupFrames:       l.warmupFrames,
+		WarmupDuration:     l.warmupDuration,
+		SpiralFrames:       l.spiralFrames,
+		SpiralRecovery:     l.spiralRecovery,
+		MaxPanics:          l.maxPanics,
+		PanicWindow:        l.panicWindow,
+		SleepStrategy:      l.sleepStrategy,
+		SpinThreshold:      l.spinThreshold,
+		EmitFrameEvents:    l.frameEvents != nil,
+		FrameEventsBuffer:  l.frameEventsBuffer,
+		CollectHistogram:   l.collectHistogram,
+		HistogramBuckets:   slices.Clone(l.histogram.Bounds),
+		Debug:              l.isDebugMode,
+		Uncapped:           l.isUncapped,
+		StepMode:           l.isStepMode,
+		InputWhilePaused:   l.inputWhilePaused,
+		IsolatePanics:      l.isolatePanics,
+		LockOSThread:       l.lockOSThread,
+		ConcurrentRender:   l.concurrentRender,
+		RenderAsyncDrop:    l.renderAsyncDrop,
+		RenderFirst:        l.renderFirst,
+		SimulationOnly:     l.simulationOnly,
+		ZeroFirstDelta:     l.zeroFirstDelta,
+		InputOnMainThread:  l.inputOnMainThread,
+	}
+}
+
+// DefaultConfig returns the configuration of a new loop, a starting point
+// to unmarshal settings into before calling NewLoopFromConfig
+func DefaultConfig() LoopConfig {
+	return NewLoop().GetConfig()
+}
+
+// NewLoopFromConfig creates a loop with the given configuration, applying every
+// field as the matching setter would, so fields left at their zero value are set
+// to zero. The configuration is checked like Validate does, without requiring
+// an update function, which can be set afterwards along with the other functions.
+func NewLoopFromConfig(config LoopConfig) (*Loop, error) {
+	l := NewLoop().
+		SetTargetFpsFloat(config.TargetFps).
+		SetRenderFps(config.RenderFps).
+		SetInputFps(config.InputFps).
+		SetFixedTimestep(config.FixedTimestep).
+		SetMaxUpdatesPerFrame(config.MaxUpdatesPerFrame).
+		SetMaxRenderSkip(config.MaxRenderSkip).
+		SetRenderOncePerFrame(config.RenderOncePerFrame).
+		SetCatchUpPolicy(config.CatchUpPolicy).
+		SetInterpolation(config.Interpolation).
+		SetMaxDeltaTime(config.MaxDeltaTime).
+		SetTimeScale(config.TimeScale).
+		SetDeltaSmoothing(config.DeltaSmoothing).
+		SetDeltaEMA(config.DeltaEMA).
+		SetFpsSampleWindow(config.FpsSampleWindow).
+		SetFpsSampleFrames(config.FpsSampleFrames).
+		SetFpsSmoothing(config.FpsSmoothing).
+		SetMinFrameTime(config.MinFrameTime).
+		SetMaxFrames(config.MaxFrames).
+		SetWarmupFrames(config.WarmupFrames).
+		SetWarmupDuration(config.WarmupDuration).
+		SetSpiralFrames(config.SpiralFrames).
+		SetSpiralRecovery(config.SpiralRecovery).
+		SetPanicLimit(config.MaxPanics, config.PanicWindow).
+		SetSleepStrategy(config.SleepStrategy).
+		SetSpinThreshold(config.SpinThreshold).
+		SetFrameEventsBuffer(config.FrameEventsBuffer).
+		SetEmitFrameEvents(config.EmitFrameEvents).
+		SetCollectHistogram(config.CollectHistogram).
+		SetHistogramBuckets(config.HistogramBuckets...).
+		SetDebug(config.Debug).
+		SetUncapped(config.Uncapped).
+		SetStepMode(config.StepMode).
+		SetInputWhilePaused(config.InputWhilePaused).
+		SetIsolatePanics(config.IsolatePanics).
+		SetLockOSThread(config.LockOSThread).
+		SetConcurrentRender(config.ConcurrentRender).
+		SetRenderAsyncDrop(config.RenderAsyncDrop).
+		SetRenderFirst(config.RenderFirst).
+		SetSimulationOnly(config.SimulationOnly).
+		SetZeroFirstDelta(config.ZeroFirstDelta).
+		SetInputOnMainThread(config.InputOnMainThread)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := errors.Join(l.configErrors()...); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
@@ -0,0 +1,111 @@
+package gyro
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// closedDone is the done channel of frame contexts asked for it past their deadline
+var closedDone = func() chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}()
+
+// SetUpdateFuncDeadline behaves like SetUpdateFunc, but update also receives a
+// context whose deadline is the end of the frame budget, e.g. for optional work
+// to bail out of once ctx.Done() is closed or ctx.Err() is set. Every fixed
+// update of a frame shares the frame deadline, and uncapped loops have none.
+// The context only signals the deadline, it never interrupts blocking code.
+//
+// The context is reused from frame to frame, so it costs nothing to create:
+// it's only valid during the update call, and must not be kept past it.
+// Done only makes its channel, and the timer closing it, once called. Err
+// follows the loop clock, while Done is closed on time with the default clock
+// only, so with a custom clock check Err instead.
+func (l *Loop) SetUpdateFuncDeadline(update UpdateFuncDeadline) *Loop {
+	return l.addSystem(system{name: DEFAULT_SYSTEM, updateDeadline: update})
+}
+
+// frameContext is the context handed to deadline updates, reset every frame
+type frameContext struct {
+	clock    Clock
+	deadline time.Time
+
+	// mu guards the done channel, the timer closing it and whether it's closed
+	mu     sync.Mutex
+	done   chan struct{}
+	timer  *time.Timer
+	closed bool
+}
+
+// reset starts a new frame with the given deadline, none when zero
+func (c *frameContext) reset(deadline time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.deadline, c.done, c.closed = deadline, nil, false
+}
+
+func (c *frameContext) Deadline() (time.Time, bool) {
+	return c.deadline, !c.deadline.IsZero()
+}
+
+func (c *frameContext) Done() <-chan struct{} {
+	if c.deadline.IsZero() {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.done != nil {
+		return c.done
+	}
+
+	remaining := c.deadline.Sub(c.clock.Now())
+	if remaining <= 0 {
+		c.done, c.closed = closedDone, true
+		return c.done
+	}
+
+	done := make(chan struct{})
+	c.done = done
+	c.timer = time.AfterFunc(remaining, func() {
+		c.expire(done)
+	})
+	return done
+}
+
+func (c *frameContext) Err() error {
+	if c.deadline.IsZero() || c.clock.Now().Before(c.deadline) {
+		return nil
+	}
+
+	// Done must be closed whenever Err is set
+	c.mu.Lock()
+	if c.done != nil && !c.closed {
+		close(c.done)
+		c.closed = true
+	}
+	c.mu.Unlock()
+	return context.DeadlineExceeded
+}
+
+func (c *frameContext) Value(key any) any {
+	return nil
+}
+
+// expire closes done, unless the frame it belongs to is already over
+func (c *frameContext) expire(done chan struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.done == done && !c.closed {
+		close(done)
+		c.closed = true
+	}
+}
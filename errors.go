@@ -1,6 +1,43 @@
 package gyro
 
+import "errors"
+
 const (
-	ERR_NO_UPDATE_FUNC    = "No update function provided."
-	ERR_QUIT_CHAN_BLOCKED = "Could not send quit signal, quit channel blocked."
+	ERR_NO_UPDATE_FUNC     = "No update function provided."
+	ERR_QUIT_CHAN_BLOCKED  = "Could not send quit signal, quit channel blocked."
+	ERR_NOT_RUNNING        = "Loop is not running."
+	ERR_RUNNING            = "Loop is running."
+	ERR_NOT_STEP_MODE      = "Loop is not in step mode."
+	ERR_PANIC_LIMIT        = "Loop stopped after too many recovered panics."
+	ERR_STOP_TIMEOUT       = "Loop did not exit within the stop timeout."
+	ERR_NOT_FIXED_TIMESTEP = "Loop is not in fixed timestep mode."
+
+	// Configuration errors
+	ERR_FIXED_UNCAPPED        = "Fixed timestep and uncapped modes can't be combined."
+	ERR_RENDER_SKIP_NOT_FIXED = "Max render skip requires fixed timestep mode."
+	ERR_RENDER_SIMULATION     = "Render function set on a simulation only loop."
+	ERR_RENDER_ONCE_SKIP      = "Render once per frame can't be combined with render skipping or dropping."
+	ERR_TICK_UNCAPPED         = "Tick source and uncapped modes can't be combined."
+	ERR_TICK_STEP_MODE        = "Tick source and step modes can't be combined."
+	ERR_TICK_TARGET_FPS       = "Target fps set on a loop paced by a tick source."
+	ERR_FPS_SAMPLE_CONFLICT   = "Fps sample window and sample frames can't be combined."
+)
+
+var (
+	ErrNoUpdateFunc     = errors.New(ERR_NO_UPDATE_FUNC)
+	ErrNotRunning       = errors.New(ERR_NOT_RUNNING)
+	ErrRunning          = errors.New(ERR_RUNNING)
+	ErrNotStepMode      = errors.New(ERR_NOT_STEP_MODE)
+	ErrPanicLimit       = errors.New(ERR_PANIC_LIMIT)
+	ErrStopTimeout      = errors.New(ERR_STOP_TIMEOUT)
+	ErrNotFixedTimestep = errors.New(ERR_NOT_FIXED_TIMESTEP)
+
+	ErrFixedUncapped      = errors.New(ERR_FIXED_UNCAPPED)
+	ErrRenderSkipNotFixed = errors.New(ERR_RENDER_SKIP_NOT_FIXED)
+	ErrRenderSimulation   = errors.New(ERR_RENDER_SIMULATION)
+	ErrRenderOnceSkip     = errors.New(ERR_RENDER_ONCE_SKIP)
+	ErrTickUncapped       = errors.New(ERR_TICK_UNCAPPED)
+	ErrTickStepMode       = errors.New(ERR_TICK_STEP_MODE)
+	ErrTickTargetFps      = errors.New(ERR_TICK_TARGET_FPS)
+	ErrFpsSampleConflict  = errors.New(ERR_FPS_SAMPLE_CONFLICT)
 )
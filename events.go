@@ -0,0 +1,75 @@
+package gyro
+
+// SetEmitFrameEvents makes the loop send every finished frame on the
+// FrameEvents channel, an alternative to the frame hooks for reactive
+// consumers. The loop never blocks on the channel: once DEFAULT_FRAME_EVENTS_BUFFER
+// events, or the size set with SetFrameEventsBuffer, are waiting, further
+// events are dropped until the consumer catches up, see GetDroppedFrameEvents.
+// It's safe to call while the loop runs.
+func (l *Loop) SetEmitFrameEvents(emit bool) *Loop {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !emit {
+		l.frameEvents = nil
+	} else if l.frameEvents == nil {
+		l.frameEvents = make(chan Frame, l.frameEventsBuffer)
+	}
+	return l
+}
+
+func (l *Loop) IsEmitFrameEvents() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.frameEvents != nil
+}
+
+// SetFrameEventsBuffer sets how many frame events are buffered for a slow
+// consumer, DEFAULT_FRAME_EVENTS_BUFFER by default. Changing it while frame
+// events are on replaces the channel, so FrameEvents must be called again.
+func (l *Loop) SetFrameEventsBuffer(n int) *Loop {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n = max(n, 1)
+	if l.frameEvents != nil && n != l.frameEventsBuffer {
+		l.frameEvents = make(chan Frame, n)
+	}
+	l.frameEventsBuffer = n
+	return l
+}
+
+func (l *Loop) GetFrameEventsBuffer() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.frameEventsBuffer
+}
+
+// FrameEvents returns the channel finished frames are sent on, with the total
+// delta time and alpha of the frame like the after frame hook receives. It's
+// nil while frame events are off. The channel is never closed, select on Done
+// to learn when the loop exits.
+func (l *Loop) FrameEvents() <-chan Frame {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.frameEvents
+}
+
+// GetDroppedFrameEvents returns how many frame events were dropped since the loop
+// last started because the buffer was full
+func (l *Loop) GetDroppedFrameEvents() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.droppedFrameEvents
+}
+
+// emitFrameEvent sends frame on events without blocking, dropping it if the buffer is full
+func (l *Loop) emitFrameEvent(events chan Frame, frame Frame) {
+	select {
+	case events <- frame:
+	default:
+		l.mu.Lock()
+		l.droppedFrameEvents++
+		l.mu.Unlock()
+	}
+}
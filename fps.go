@@ -0,0 +1,97 @@
+package gyro
+
+import (
+	"math"
+	"time"
+)
+
+// FpsCounter defines how the current fps is measured. The loop calls Tick once
+// per frame with the time since the previous frame start, and Value whenever
+// the current fps is read. Both are called with the loop lock held, so an
+// implementation needs no locking of its own but must not call back into the loop.
+type FpsCounter interface {
+	Tick(frameDuration time.Duration)
+	Value() float64
+}
+
+// WindowFpsCounter reports the average fps over consecutive windows of frames,
+// like the loop's default fps sample
+type WindowFpsCounter struct {
+	window  time.Duration
+	frames  int
+	elapsed time.Duration
+	value   float64
+}
+
+// NewWindowFpsCounter returns a counter reporting the fps of the last full window
+func NewWindowFpsCounter(window time.Duration) *WindowFpsCounter {
+	return &WindowFpsCounter{window: max(window, time.Millisecond)}
+}
+
+func (c *WindowFpsCounter) Tick(frameDuration time.Duration) {
+	c.frames++
+	c.elapsed += frameDuration
+	if c.elapsed < c.window {
+		return
+	}
+
+	c.value = float64(c.frames) / c.elapsed.Seconds()
+	c.frames = 0
+	c.elapsed = 0
+}
+
+func (c *WindowFpsCounter) Value() float64 {
+	return c.value
+}
+
+// EmaFpsCounter reports the fps from an exponential moving average of frame durations
+type EmaFpsCounter struct {
+	factor float64
+	avg    float64
+}
+
+// NewEmaFpsCounter returns a counter giving factor, between 0 and 1, as weight
+// to the latest frame. Values out of range fall back to DEFAULT_FPS_SMOOTHING.
+func NewEmaFpsCounter(factor float64) *EmaFpsCounter {
+	if factor <= 0 || factor > 1 {
+		factor = DEFAULT_FPS_SMOOTHING
+	}
+	return &EmaFpsCounter{factor: factor}
+}
+
+func (c *EmaFpsCounter) Tick(frameDuration time.Duration) {
+	if frameDuration <= 0 {
+		return
+	}
+
+	if c.avg == 0 {
+		c.avg = float64(frameDuration)
+	} else {
+		c.avg += c.factor * (float64(frameDuration) - c.avg)
+	}
+}
+
+func (c *EmaFpsCounter) Value() float64 {
+	if c.avg == 0 {
+		return 0
+	}
+	return float64(time.Second) / c.avg
+}
+
+// SetFpsCounter replaces how GetCurrentFps, Snapshot and Frame measure the
+// current fps. A nil counter restores the default fps sample, which also keeps
+// driving the fps sample hooks and the update and render fps. Set it before Start.
+func (l *Loop) SetFpsCounter(counter FpsCounter) *Loop {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.fpsCounter = counter
+	return l
+}
+
+// fps returns the current fps of the active counter, mu must be held
+func (l *Loop) fps() int {
+	if l.fpsCounter != nil {
+		return int(math.Round(l.fpsCounter.Value()))
+	}
+	return l.currentFps
+}
@@ -0,0 +1,44 @@
+package gyro
+
+import "time"
+
+type UpdateFuncCtx func(frame Frame)
+type RenderFuncCtx func(frame Frame)
+
+// Frame is the per-frame context handed to the Ctx callbacks. It is passed by
+// value so a running loop does not allocate per frame
+type Frame struct {
+	// Number is the index of the frame since the loop last started, from zero
+	Number uint64
+
+	// Delta is the delta time of the update call, or the total delta time
+	// handed to update this frame when passed to render
+	Delta time.Duration
+
+	// RealDelta is the delta time of the update call before the time scale,
+	// see SetUpdateFuncRealScaled
+	RealDelta time.Duration
+
+	// Elapsed is the time the loop has been running as of the frame start
+	Elapsed time.Duration
+
+	// Alpha is the fixed timestep interpolation factor, only set for render
+	Alpha float64
+
+	// Fps is the current fps as of the last sample
+	Fps int
+}
+
+// SetUpdateFuncCtx behaves like SetUpdateFunc, but update receives the whole frame
+func (l *Loop) SetUpdateFuncCtx(update UpdateFuncCtx) *Loop {
+	return l.AddSystemCtx(DEFAULT_SYSTEM, update)
+}
+
+// SetRenderFuncCtx behaves like SetRenderFunc, but render receives the whole frame.
+// It takes precedence over the render layers and SetRenderFuncAlpha.
+func (l *Loop) SetRenderFuncCtx(render RenderFuncCtx) *Loop {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.renderFrame = render
+	return l
+}
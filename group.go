@@ -0,0 +1,144 @@
+package gyro
+
+import (
+	"errors"
+	"slices"
+	"sync"
+)
+
+// LoopGroup runs several loops that start and stop together,
+// e.g. a simulation loop and a render loop
+type LoopGroup struct {
+	loops   []*Loop
+	running bool
+	done    chan struct{}
+
+	// mu guards the loops, the running flag and the done channel
+	mu sync.Mutex
+}
+
+func NewLoopGroup(loops ...*Loop) *LoopGroup {
+	return &LoopGroup{
+		loops: slices.Clone(loops),
+		done:  make(chan struct{}),
+	}
+}
+
+// Add adds a loop to the group. Loops added while the group runs
+// are only started by the next Start.
+func (g *LoopGroup) Add(l *Loop) *LoopGroup {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.loops = append(g.loops, l)
+	return g
+}
+
+// Start starts every loop of the group concurrently and blocks until all of
+// them exited. The first loop to exit, for any reason, stops the others.
+// It returns the errors of the loops joined in the order they were added,
+// or ErrRunning if the group is already running. If a loop fails to start,
+// the loops already started are stopped again.
+func (g *LoopGroup) Start() error {
+	g.mu.Lock()
+	if g.running {
+		g.mu.Unlock()
+		return ErrRunning
+	}
+
+	loops := slices.Clone(g.loops)
+	g.running = true
+	select {
+	case <-g.done:
+		g.done = make(chan struct{})
+	default:
+	}
+	done := g.done
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		g.running = false
+		close(done)
+		g.mu.Unlock()
+	}()
+
+	type exit struct {
+		index int
+		err   error
+	}
+
+	errs := make([]error, len(loops))
+	exits := make(chan exit, len(loops))
+	started := 0
+	for i, l := range loops {
+		result, err := l.StartAsync()
+		if err != nil {
+			errs[i] = err
+			break
+		}
+
+		started++
+		go func(i int, result <-chan error) {
+			exits <- exit{index: i, err: <-result}
+		}(i, result)
+	}
+
+	if started < len(loops) {
+		stopLoops(loops[:started])
+	}
+
+	for i := 0; i < started; i++ {
+		exited := <-exits
+		if i == 0 {
+			stopLoops(loops[:started])
+		}
+		errs[exited.index] = exited.err
+	}
+
+	return errors.Join(errs...)
+}
+
+// Stop stops every loop of the group and waits until all of them exited.
+// It must not be called from a callback of one of the loops, which would
+// wait for itself, but stopping any loop from a callback stops the group.
+func (g *LoopGroup) Stop() error {
+	g.mu.Lock()
+	loops, running, done := slices.Clone(g.loops), g.running, g.done
+	g.mu.Unlock()
+
+	err := stopLoops(loops)
+	if running {
+		<-done
+	}
+	return err
+}
+
+// Done returns a channel that is closed once every loop of the group exited
+// and Start returned. Like Loop.Done, a new channel is made for the next run.
+func (g *LoopGroup) Done() <-chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.done
+}
+
+// IsRunning reports whether the group was started and Start hasn't returned yet
+func (g *LoopGroup) IsRunning() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.running
+}
+
+func (g *LoopGroup) GetLoops() []*Loop {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return slices.Clone(g.loops)
+}
+
+// stopLoops stops every loop, returning the stop errors joined
+func stopLoops(loops []*Loop) error {
+	var errs []error
+	for _, l := range loops {
+		errs = append(errs, l.Stop())
+	}
+	return errors.Join(errs...)
+}
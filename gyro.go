@@ -1,168 +1,2641 @@
 package gyro
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"runtime"
 	"sync"
 	"time"
 )
 
 const (
-	DEFAULT_FPS = 60
+	DEFAULT_FPS                   = 60
+	DEFAULT_MAX_UPDATES_PER_FRAME = 5
+	DEFAULT_FPS_SAMPLE_WINDOW     = time.Second
+	DEFAULT_FPS_SMOOTHING         = 0.1
+
+	// Margin above the sustained overrun threshold the fps has to recover to
+	// before the hook can fire again, so it doesn't flap around the threshold
+	SUSTAINED_OVERRUN_HYSTERESIS = 0.05
+
+	// Consecutive overrun frames before the spiral hook fires
+	DEFAULT_SPIRAL_FRAMES = 10
+
+	// Isolated panics within the panic window that stop the loop
+	DEFAULT_MAX_PANICS   = 10
+	DEFAULT_PANIC_WINDOW = time.Second
+
+	// Frame events buffered for a slow consumer before they're dropped
+	DEFAULT_FRAME_EVENTS_BUFFER = 64
+
+	// Relative error between a requested and the effective rate that fires a config warning
+	RATE_WARNING_TOLERANCE = 0.001
+)
+
+// Fixed timestep catch-up policies, deciding how the accumulated backlog
+// of updates is consumed when a frame falls behind
+const (
+	// Run up to the max updates per frame, dropping any backlog beyond that.
+	// Deterministic while the loop keeps up, the dropped time is lost otherwise.
+	CATCH_UP_CLAMPED CatchUpPolicy = iota
+
+	// Run every update owed, keeping the simulation time exactly in sync with
+	// real time. Fully deterministic, but a frame slower than its updates can
+	// spiral, so it's best paired with SetMaxDeltaTime.
+	CATCH_UP
+
+	// Run at most one update per frame, dropping the rest of the backlog.
+	// The simulation slows down instead of catching up when frames fall behind.
+	DROP_TO_LATEST
+)
+
+// Interpolation modes, deciding when render receives a nonzero alpha
+const (
+	// Always hand render the interpolation alpha in fixed timestep mode
+	INTERPOLATION_ALWAYS Interpolation = iota
+
+	// Only interpolate when render runs more often than the fixed updates, its
+	// period, the render fps or else target fps one, being shorter than the
+	// fixed timestep. Otherwise every render follows a fresh update and alpha is 0.
+	INTERPOLATION_AUTO
+
+	// Never interpolate, alpha is always 0
+	INTERPOLATION_NEVER
+)
+
+// Loop phases reported in PanicInfo
+const (
+	PHASE_INPUT       = "input"
+	PHASE_UPDATE      = "update"
+	PHASE_LATE_UPDATE = "late_update"
+	PHASE_RENDER      = "render"
+	PHASE_PRESENT     = "present"
 )
 
 type InputFunc func()
+type InputFuncBool func() bool
 type UpdateFunc func(deltaTime time.Duration)
 type RenderFunc func()
+type RenderFuncAlpha func(alpha float64)
 type RecoverFunc func(any)
+type RecoverDecideFunc func(any) bool
+type StatsFunc func(FrameStats)
+type HookFunc func()
+type OverrunFunc func(over time.Duration)
+type SleepFunc func(d time.Duration)
+type UpdateMiddleware func(next UpdateFunc) UpdateFunc
+type FpsFunc func(fps int)
+type UpdateFuncErr func(deltaTime time.Duration) error
+type UpdateFuncRealScaled func(scaled, real time.Duration)
+type UpdateFuncDeadline func(ctx context.Context, deltaTime time.Duration)
+type DeltaSourceFunc func() time.Duration
+type TargetFpsFunc func() int
+type FrameHookFunc func(frame Frame)
+type PanicLimitFunc func(last PanicInfo)
+type ConfigWarningFunc func(warning string)
+type CatchUpPolicy int
+type Interpolation int
+
+// PanicInfo is passed to the recover function when a panic
+// is recovered from an isolated loop phase
+type PanicInfo struct {
+	Phase string
+	Value any
+}
+
+// callbacks holds every function set on a loop, kept apart so Reset can preserve them
+type callbacks struct {
+	// Loop functions, guarded by mu and loaded once at the start of every frame
+	input         InputFunc
+	inputBool     InputFuncBool
+	systems       []system
+	middleware    []UpdateMiddleware
+	lateUpdate    UpdateFunc
+	layers        []renderLayer
+	renderAlpha   RenderFuncAlpha
+	renderFrame   RenderFuncCtx
+	onPresent     HookFunc
+	recoverFunc   RecoverFunc
+	recoverDecide RecoverDecideFunc
+	statsFunc     StatsFunc
+	deltaSource   DeltaSourceFunc
+
+	// Lifecycle hooks
+	onStart        HookFunc
+	onStop         HookFunc
+	onFrameOverrun OverrunFunc
+	onBeforeSleep  SleepFunc
+	onFpsSample    FpsFunc
+	onWarmupDone   HookFunc
+	onFirstUpdate  HookFunc
+
+	// Quality tier recommendations, see SetQualityController
+	qualityController QualityController
+	onQualityChange   QualityFunc
+
+	// Frame hooks, guarded by mu like the loop functions
+	beforeFrame   FrameHookFunc
+	afterFrame    FrameHookFunc
+	targetFpsFunc TargetFpsFunc
+
+	// Spiral hook, fired after spiralFrames consecutive overruns
+	onSpiral HookFunc
+
+	// Panic limit hook, fired when isolated panics stop the loop
+	onPanicLimit PanicLimitFunc
+
+	// Config warning hook, fired when a setter adjusts the value it was given
+	onConfigWarning ConfigWarningFunc
+
+	// Sustained overrun hook, fired when the fps drops below threshold * target fps
+	onSustainedOverrun        FpsFunc
+	sustainedOverrunThreshold float64
+}
 
 type Loop struct {
 	// Loop Config
-	targetFps  int
-	msPerFrame int
-	stopCh     chan struct{}
+	targetFps   float64
+	framePeriod time.Duration
+	stopCh      chan struct{}
+	clock       Clock
+	debugWriter io.Writer
+	logger      *slog.Logger
+	startedCh   chan struct{}
+	doneCh      chan struct{}
+	stepCh      chan stepRequest
+	wakeCh      chan struct{}
+	tickSource  <-chan time.Time
+
+	// Render and input rate config, they run every frame when their fps is zero
+	renderFps    int
+	renderPeriod time.Duration
+	inputFps     int
+	inputPeriod  time.Duration
+
+	// Fixed timestep config, disabled when fixedTimestep is zero
+	fixedTimestep      time.Duration
+	maxUpdatesPerFrame int
+	maxRenderSkip      int
+	renderOncePerFrame bool
+	catchUpPolicy      CatchUpPolicy
+	interpolation      Interpolation
+
+	// Upper bound for delta time, unlimited when zero
+	maxDeltaTime   time.Duration
+	timeScale      float64
+	deltaSmoothing int
+	deltaEma       float64
+
+	fpsSampleWindow time.Duration
+	fpsSampleFrames int
+	fpsSmoothing    float64
+	fpsCounter      FpsCounter
+
+	// Shortest time a frame can take, disabled when zero
+	minFrameTime time.Duration
+
+	// Capacity of the frame events channel
+	frameEventsBuffer int
+
+	// Frame time histogram, guarded by mu, counted while collectHistogram is set
+	collectHistogram bool
+	histogram        Histogram
+
+	// Frames every run stops after, unlimited when zero,
+	// and the frames of the current RunFrames call
+	maxFrames int
+	runFrames int
+
+	// Deadline of the current RunUntil call, none when zero
+	runUntil time.Time
+
+	// Spiral of death detection, see SetOnSpiral
+	spiralFrames   int
+	spiralRecovery bool
+
+	// Isolated panics within panicWindow that stop the loop, unlimited when zero
+	maxPanics   int
+	panicWindow time.Duration
+
+	// How the default clock sleeps between frames
+	sleepStrategy SleepStrategy
+	spinThreshold time.Duration
+
+	// Warmup ignored by the fps and frame time stats, disabled when both are zero
+	warmupFrames   int
+	warmupDuration time.Duration
 
 	// Flags
-	isDebugMode bool
-	isRunning   bool
+	isDebugMode       bool
+	isUncapped        bool
+	isRunning         bool
+	isPaused          bool
+	inputWhilePaused  bool
+	isDraining        bool
+	isStopping        bool
+	isolatePanics     bool
+	isStepMode        bool
+	lockOSThread      bool
+	concurrentRender  bool
+	renderAsyncDrop   bool
+	renderFirst       bool
+	simulationOnly    bool
+	zeroFirstDelta    bool
+	inputOnMainThread bool
 
-	// Loop functions
-	input       InputFunc
-	update      UpdateFunc
-	render      RenderFunc
-	recoverFunc RecoverFunc
+	// Loop functions and hooks
+	callbacks
 
 	// Runtime values
-	currentFps int
+	currentFps       int
+	currentFpsFloat  float64
+	currentUpdateFps int
+	currentRenderFps int
+	frameCount       uint64
+	droppedFrames    uint64
+	droppedRenders   uint64
+	elapsed          time.Duration
+	lastFrameTime    time.Duration
+	frameStart       time.Time
+	frameTimeStats   FrameTimeStats
+	qualityTier      int
+
+	// Error returned by an UpdateFuncErr that stopped the current run
+	stopErr error
+
+	// Delta time handed to the last update, and the real time it covered
+	lastDelta    time.Duration
+	lastRawDelta time.Duration
+
+	// Sleep time of the last frame, zero when it had no headroom
+	lastSleep time.Duration
+
+	// Time spent paused in the current run, up to the pause still going on
+	// since pausedSince, if paused
+	pausedTotal time.Duration
+	pausedSince time.Time
+
+	// Fixed timestep accumulator as of the last frame, and the value set
+	// through SetAccumulator for the next frame
+	accumulator        time.Duration
+	pendingAccumulator time.Duration
+	hasAccumulator     bool
+
+	// Frame events channel, nil unless frame events are on, and the events dropped
+	frameEvents        chan Frame
+	droppedFrameEvents uint64
 
+	// mu guards the flags and runtime values read from other goroutines
+	mu   sync.Mutex
 	once sync.Once
 }
 
 func NewLoop() *Loop {
 	l := &Loop{}
-	l.SetTargetFps(DEFAULT_FPS)
+	l.init()
 	return l
 }
 
+// init sets the default configuration of a new loop
+func (l *Loop) init() {
+	l.clock = realClock{}
+	l.debugWriter = os.Stderr
+	l.startedCh = make(chan struct{})
+	l.doneCh = make(chan struct{})
+	l.stepCh = make(chan stepRequest)
+	l.wakeCh = make(chan struct{}, 1)
+	l.SetTargetFps(DEFAULT_FPS)
+	l.SetMaxUpdatesPerFrame(DEFAULT_MAX_UPDATES_PER_FRAME)
+	l.SetTimeScale(1)
+	l.SetDeltaSmoothing(1)
+	l.SetFpsSampleWindow(DEFAULT_FPS_SAMPLE_WINDOW)
+	l.SetFpsSmoothing(DEFAULT_FPS_SMOOTHING)
+	l.SetSpinThreshold(SPIN_THRESHOLD)
+	l.SetSpiralFrames(DEFAULT_SPIRAL_FRAMES)
+	l.SetSpiralRecovery(true)
+	l.SetPanicLimit(DEFAULT_MAX_PANICS, DEFAULT_PANIC_WINDOW)
+	l.SetFrameEventsBuffer(DEFAULT_FRAME_EVENTS_BUFFER)
+	l.SetHistogramBuckets(defaultHistogramBounds...)
+}
+
+// Reset returns a stopped loop to the default configuration of NewLoop and clears
+// its runtime state, so it can be reused, e.g. from a pool. Loop functions, systems
+// and hooks survive the reset when keepCallbacks is set and are cleared otherwise.
+// It returns ErrRunning if the loop is running or still stopping, so after Stop
+// wait for Done before resetting. It must not be called concurrently with other methods.
+func (l *Loop) Reset(keepCallbacks bool) error {
+	l.mu.Lock()
+	if l.isRunning || l.isStopping {
+		l.mu.Unlock()
+		return ErrRunning
+	}
+
+	var kept callbacks
+	if keepCallbacks {
+		kept = l.callbacks
+	}
+	l.mu.Unlock()
+
+	*l = Loop{callbacks: kept}
+	l.init()
+	return nil
+}
+
+// SetDebug enables a per-frame debug trace of the frame number, delta time,
+// phase timings and sleep time, written to the debug writer, or logged to the
+// logger if one is set
 func (l *Loop) SetDebug(debug bool) *Loop {
 	l.isDebugMode = debug
 	return l
 }
 
+// SetDebugWriter sets where the debug trace is written, os.Stderr by default
+func (l *Loop) SetDebugWriter(w io.Writer) *Loop {
+	if w == nil {
+		w = os.Stderr
+	}
+	l.debugWriter = w
+	return l
+}
+
 func (l *Loop) SetTargetFps(fps int) *Loop {
+	return l.SetTargetFpsFloat(float64(fps))
+}
+
+// SetTargetFpsFloat sets a fractional target fps, e.g. 59.94.
+// Rates below 1 are clamped to 1. It's safe to call while the loop runs,
+// the new rate applies from the next frame on.
+func (l *Loop) SetTargetFpsFloat(fps float64) *Loop {
+	l.mu.Lock()
 	l.targetFps = max(fps, 1)
-	l.msPerFrame = int(1.0 / float32(l.targetFps) * 1000)
+	l.framePeriod = time.Duration(float64(time.Second) / l.targetFps)
+	period := l.framePeriod
+	l.mu.Unlock()
+
+	if fps < 1 {
+		l.warn(fmt.Sprintf("gyro: target fps %v is below 1, running at 1 fps", fps))
+	} else {
+		l.warnRate("target fps", fps, period)
+	}
+	return l
+}
+
+// SetOnConfigWarning sets a function told when a setter can't apply the value it
+// was given as is, e.g. a target fps below 1, or a rate whose frame period, in
+// whole nanoseconds, is off by more than RATE_WARNING_TOLERANCE. The warning
+// describes the value used instead. It's purely diagnostic.
+func (l *Loop) SetOnConfigWarning(onConfigWarning ConfigWarningFunc) *Loop {
+	l.onConfigWarning = onConfigWarning
+	return l
+}
+
+// warn hands warning to the config warning hook, if set
+func (l *Loop) warn(warning string) {
+	if l.onConfigWarning != nil {
+		l.onConfigWarning(warning)
+	}
+}
+
+// warnRate warns when the rate period runs at doesn't match the requested fps
+func (l *Loop) warnRate(name string, fps float64, period time.Duration) {
+	effective := float64(time.Second) / float64(period)
+	if math.Abs(effective-fps)/fps > RATE_WARNING_TOLERANCE {
+		l.warn(fmt.Sprintf("gyro: %s %v runs at %.3f fps, with a frame period of %v", name, fps, effective, period))
+	}
+}
+
+// SetTargetFpsFunc sets a function deciding the target fps from any external
+// signal, e.g. 30 on battery and 60 on AC power. It's called when the loop starts
+// and then after every fps sample, once per fps sample window, and the frame
+// period is derived again whenever the returned fps changes.
+func (l *Loop) SetTargetFpsFunc(targetFps TargetFpsFunc) *Loop {
+	l.targetFpsFunc = targetFps
 	return l
 }
 
+// GetTargetFps returns the target fps rounded to the nearest integer
 func (l *Loop) GetTargetFps() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(math.Round(l.targetFps))
+}
+
+func (l *Loop) GetTargetFpsFloat() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	return l.targetFps
 }
 
-func (l *Loop) GetCurrentFps() int {
-	return l.currentFps
+// GetTargetPeriod returns the duration of a frame at the target fps
+func (l *Loop) GetTargetPeriod() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.framePeriod
 }
 
-func (l *Loop) IsRunning() bool {
-	return l.isRunning
+// SetUncapped makes the loop run frames as fast as possible, without sleeping
+// between them. The current fps is still measured, but expect the loop
+// to fully use a CPU core while running uncapped.
+func (l *Loop) SetUncapped(uncapped bool) *Loop {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.isUncapped = uncapped
+	return l
 }
 
-func (l *Loop) SetUpdateFunc(update UpdateFunc) *Loop {
-	l.update = update
+func (l *Loop) IsUncapped() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.isUncapped
+}
+
+// SetCapped switches between pacing frames to the target fps and running
+// uncapped, e.g. from a hotkey to compare both. It's safe to call while the
+// loop runs, taking effect from the next frame on, and leaves the target fps
+// as it is. Uncapped frames aren't on the frame schedule, which restarts from
+// the start of the first capped frame, so that frame doesn't try to make up
+// for the uncapped ones and the deltas after it are regular, without a spike.
+func (l *Loop) SetCapped(capped bool) *Loop {
+	return l.SetUncapped(!capped)
+}
+
+func (l *Loop) IsCapped() bool {
+	return !l.IsUncapped()
+}
+
+// SetMinFrameTime makes the loop sleep until every frame took at least d, capping
+// the fps without pacing frames to a cadence. It's meant for uncapped loops, which
+// then run as fast as possible up to that rate, and frames taking longer aren't
+// overruns. With a target fps, the longer of the target period and d is the frame
+// budget. A zero or negative d disables the floor.
+func (l *Loop) SetMinFrameTime(d time.Duration) *Loop {
+	l.minFrameTime = max(d, 0)
 	return l
 }
 
-func (l *Loop) SetInputFunc(input InputFunc) *Loop {
-	l.input = input
+func (l *Loop) GetMinFrameTime() time.Duration {
+	return l.minFrameTime
+}
+
+// SetClock sets the time source used for frame timing and pacing, e.g. a FakeClock
+// for deterministic tests. A nil clock restores the real one.
+// It must be set before the loop starts.
+func (l *Loop) SetClock(clock Clock) *Loop {
+	if clock == nil {
+		clock = realClock{}
+	}
+	l.clock = clock
 	return l
 }
 
-func (l *Loop) SetRenderFunc(render RenderFunc) *Loop {
-	l.render = render
+// SetSimulationOnly marks a loop without a render phase, e.g. an authoritative
+// game server. Start fails with ErrRenderSimulation if a render function is set,
+// and render is never called. Input, pacing and delta times work as usual.
+// Combined with SetUncapped, the simulation runs as fast as possible,
+// e.g. to fast-forward or replay a match.
+func (l *Loop) SetSimulationOnly(simulationOnly bool) *Loop {
+	l.simulationOnly = simulationOnly
 	return l
 }
 
-func (l *Loop) SetRecoverFunc(recover RecoverFunc) *Loop {
-	l.recoverFunc = recover
+func (l *Loop) IsSimulationOnly() bool {
+	return l.simulationOnly
+}
+
+// SetTickSource makes the loop run a frame on every value received from tick,
+// e.g. a time.Ticker or a network heartbeat, instead of sleeping to pace frames.
+// GetCurrentFps then reports the externally driven rate. The loop stops once
+// tick is closed. A nil tick restores the loop's own pacing. Set it before Start.
+func (l *Loop) SetTickSource(tick <-chan time.Time) *Loop {
+	l.tickSource = tick
 	return l
 }
 
-// Start attempts to start the game loop.
-// It requires an update function to be set and
-// it will run just once for each Loop instance.
-func (l *Loop) Start() error {
-	if l.recoverFunc != nil {
-		defer func() {
-			if r := recover(); r != nil {
-				l.recoverFunc(r)
-			}
-		}()
+// SetRenderFps makes render run at its own rate, independent of the target fps
+// used for updates. The loop then runs frames at the faster of both rates.
+// A zero or negative fps restores rendering once per frame.
+func (l *Loop) SetRenderFps(fps int) *Loop {
+	if fps <= 0 {
+		l.renderFps = 0
+		l.renderPeriod = 0
+		return l
 	}
 
-	if l.update == nil {
-		return errors.New(ERR_NO_UPDATE_FUNC)
+	l.renderFps = fps
+	l.renderPeriod = time.Second / time.Duration(l.renderFps)
+	l.warnRate("render fps", float64(fps), l.renderPeriod)
+	return l
+}
+
+func (l *Loop) GetRenderFps() int {
+	return l.renderFps
+}
+
+// SetInputFps makes input get polled at its own rate, usually faster than update
+// to reduce input latency. The loop then runs frames at the fastest of the update,
+// render and input rates. Input gathered by every poll accumulates on the caller's
+// side until the next update consumes it, since update only runs once due.
+// A zero or negative fps restores polling input once per frame.
+func (l *Loop) SetInputFps(fps int) *Loop {
+	if fps <= 0 {
+		l.inputFps = 0
+		l.inputPeriod = 0
+		return l
 	}
 
-	if l.isRunning {
-		return nil
+	l.inputFps = fps
+	l.inputPeriod = time.Second / time.Duration(l.inputFps)
+	l.warnRate("input fps", float64(fps), l.inputPeriod)
+	return l
+}
+
+func (l *Loop) GetInputFps() int {
+	return l.inputFps
+}
+
+// SetFixedTimestep switches the loop to fixed timestep mode, where update
+// is called zero or more times per frame with a constant delta time d.
+// A zero or negative d restores the default variable timestep mode.
+func (l *Loop) SetFixedTimestep(d time.Duration) *Loop {
+	l.fixedTimestep = max(d, 0)
+	return l
+}
+
+// GetAccumulator returns the fixed timestep accumulator, the time not yet consumed
+// by fixed updates as of the last frame, or the value set by SetAccumulator until
+// the next frame applies it. It's always zero outside of fixed timestep mode.
+func (l *Loop) GetAccumulator() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.fixedTimestep == 0 {
+		return 0
+	}
+	if l.hasAccumulator {
+		return l.pendingAccumulator
+	}
+	return l.accumulator
+}
+
+// SetAccumulator replaces the fixed timestep accumulator, e.g. to snap the
+// simulation to an authoritative server tick. It applies at the start of the next
+// frame, before the time elapsed since the last frame is added, so an accumulator
+// of a fixed timestep or more runs the matching updates right away. Negative
+// values are clamped to zero. It returns ErrNotFixedTimestep outside of fixed
+// timestep mode.
+func (l *Loop) SetAccumulator(d time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.fixedTimestep == 0 {
+		return ErrNotFixedTimestep
 	}
 
-	l.isRunning = true
-	l.run()
+	l.pendingAccumulator, l.hasAccumulator = max(d, 0), true
 	return nil
 }
 
-// Stop attempts to stop the game loop by sending a stop signal
-func (l *Loop) Stop() error {
-	if !l.isRunning {
-		return nil
+func (l *Loop) GetFixedTimestep() time.Duration {
+	return l.fixedTimestep
+}
+
+func (l *Loop) IsFixedTimestep() bool {
+	return l.fixedTimestep > 0
+}
+
+// SetMaxUpdatesPerFrame caps how many fixed updates can run in a single frame
+// under the CATCH_UP_CLAMPED policy. Any backlog exceeding the cap is dropped
+// to avoid the loop falling further behind.
+func (l *Loop) SetMaxUpdatesPerFrame(n int) *Loop {
+	l.maxUpdatesPerFrame = max(n, 1)
+	return l
+}
+
+func (l *Loop) GetMaxUpdatesPerFrame() int {
+	return l.maxUpdatesPerFrame
+}
+
+// SetCatchUpPolicy sets how fixed timestep mode consumes the backlog of updates
+// when frames fall behind, CATCH_UP_CLAMPED by default
+func (l *Loop) SetCatchUpPolicy(policy CatchUpPolicy) *Loop {
+	l.catchUpPolicy = policy
+	return l
+}
+
+func (l *Loop) GetCatchUpPolicy() CatchUpPolicy {
+	return l.catchUpPolicy
+}
+
+// SetInterpolation sets when render receives the interpolation alpha,
+// INTERPOLATION_ALWAYS by default. With INTERPOLATION_AUTO the alpha is
+// skipped when render runs no faster than the fixed updates.
+func (l *Loop) SetInterpolation(interpolation Interpolation) *Loop {
+	l.interpolation = interpolation
+	return l
+}
+
+func (l *Loop) GetInterpolation() Interpolation {
+	return l.interpolation
+}
+
+// SetMaxRenderSkip lets fixed timestep mode skip render on up to n consecutive
+// frames that had to run more than one update to catch up, so at least one
+// frame in every n+1 is still rendered. Zero, the default, never skips render.
+func (l *Loop) SetMaxRenderSkip(n int) *Loop {
+	l.maxRenderSkip = max(n, 0)
+	return l
+}
+
+func (l *Loop) GetMaxRenderSkip() int {
+	return l.maxRenderSkip
+}
+
+// SetRenderOncePerFrame guarantees render runs once per frame, after every
+// update of the frame completed, however many fixed steps ran to catch up.
+// Render is never called per update, so this only rules out the options that
+// leave a frame without render: SetMaxRenderSkip and SetRenderAsyncDrop.
+func (l *Loop) SetRenderOncePerFrame(once bool) *Loop {
+	l.renderOncePerFrame = once
+	return l
+}
+
+func (l *Loop) IsRenderOncePerFrame() bool {
+	return l.renderOncePerFrame
+}
+
+// SetRenderFirst reverses the frame order to input, render, then update, so
+// the previous frame is presented right away and the next one computed after,
+// which lowers the perceived input latency. Render then shows the state left by
+// the previous frame's updates, and gets their delta time and interpolation
+// alpha, while the input polled this frame only shows up in the next render.
+// Render is never dropped to catch up, so SetMaxRenderSkip has no effect.
+func (l *Loop) SetRenderFirst(first bool) *Loop {
+	l.renderFirst = first
+	return l
+}
+
+func (l *Loop) IsRenderFirst() bool {
+	return l.renderFirst
+}
+
+// SetMaxDeltaTime caps the delta time handed to update, so a stalled frame
+// doesn't produce a huge time step. In fixed timestep mode it caps the time
+// added to the accumulator instead. A zero or negative d means unlimited.
+func (l *Loop) SetMaxDeltaTime(d time.Duration) *Loop {
+	l.maxDeltaTime = max(d, 0)
+	return l
+}
+
+func (l *Loop) GetMaxDeltaTime() time.Duration {
+	return l.maxDeltaTime
+}
+
+// SetTimeScale multiplies the delta time handed to update without affecting
+// the frame pacing, e.g. 0.5 for half speed. A scale of 0 freezes the
+// simulation while render keeps being called. It's safe to call while running.
+func (l *Loop) SetTimeScale(scale float64) *Loop {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.timeScale = max(scale, 0)
+	return l
+}
+
+func (l *Loop) GetTimeScale() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.timeScale
+}
+
+// SetDeltaSource sets a function supplying the delta time handed to update,
+// or fed to the accumulator in fixed timestep mode, in place of the real time
+// elapsed since the last frame, e.g. to replay a recorded sequence of deltas.
+// Its deltas are used as is: smoothing, the time scale and the max delta time
+// are not applied to them. Step with a zero delta time also draws from it.
+// Frame pacing still uses the clock, use SetUncapped or step mode to disable it.
+// A nil function restores the real delta time. It's safe to call while running.
+func (l *Loop) SetDeltaSource(source DeltaSourceFunc) *Loop {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.deltaSource = source
+	return l
+}
+
+// SetDeltaSmoothing makes the delta time handed to update the rolling average
+// of the last n real frame deltas, before the time scale and max delta time
+// are applied. The window restarts on every Start and Resume. It has no effect
+// in fixed timestep mode, and n <= 1 disables smoothing.
+func (l *Loop) SetDeltaSmoothing(n int) *Loop {
+	l.deltaSmoothing = max(n, 1)
+	return l
+}
+
+func (l *Loop) GetDeltaSmoothing() int {
+	return l.deltaSmoothing
+}
+
+// SetDeltaEMA makes the delta time handed to update an exponential moving average
+// of the real frame deltas, each new delta weighted by alpha in (0, 1]. Unlike the
+// rolling average of SetDeltaSmoothing, a spike is dampened right away and then
+// decays over the next frames instead of dropping out at once. It's applied after
+// the rolling average, if both are set, and before the time scale. The max delta
+// time caps the smoothed delta, so a capped spike still raises the next deltas.
+// The average restarts on every Start and Resume. It has no effect in fixed
+// timestep mode, and an alpha of 0 or 1 disables it.
+func (l *Loop) SetDeltaEMA(alpha float64) *Loop {
+	l.deltaEma = min(max(alpha, 0), 1)
+	return l
+}
+
+func (l *Loop) GetDeltaEMA() float64 {
+	return l.deltaEma
+}
+
+// SetFpsSampleWindow sets how often the current fps is sampled, 1s by default.
+// The frames counted within each window are scaled to a per-second rate, and
+// the OnFpsSample hook fires once per window. Windows shorter than a frame
+// sample every frame. A zero or negative d restores the default. The window is
+// only used while no sample frame count is set with SetFpsSampleFrames.
+func (l *Loop) SetFpsSampleWindow(d time.Duration) *Loop {
+	if d <= 0 {
+		d = DEFAULT_FPS_SAMPLE_WINDOW
 	}
+	l.fpsSampleWindow = d
+	return l
+}
 
-	l.isRunning = false
-	close(l.stopCh)
-	return nil
+func (l *Loop) GetFpsSampleWindow() time.Duration {
+	return l.fpsSampleWindow
 }
 
-func (l *Loop) run() {
-	l.stopCh = make(chan struct{})
-	frameCounter := 0
-	lastFrame := time.Now()
-	lastSecond := time.Now()
+// SetFpsSampleFrames samples the current fps every n frames instead of every
+// sample window, from the time the n frames took, e.g. the fps over the last
+// 120 frames. Samples then line up with frames whatever the clock does, which
+// keeps tests deterministic. It replaces the time window, so combining it with
+// a sample window other than the default fails validation with
+// ErrFpsSampleConflict. Zero or a negative n returns to time based samples.
+func (l *Loop) SetFpsSampleFrames(n int) *Loop {
+	l.fpsSampleFrames = max(n, 0)
+	return l
+}
 
-	for {
-		select {
-		case <-l.stopCh:
-			return
-		default:
-			start := time.Now()
+func (l *Loop) GetFpsSampleFrames() int {
+	return l.fpsSampleFrames
+}
 
-			if l.input != nil {
-				l.input()
-			}
+// SetFpsSmoothing sets the weight, between 0 and 1, given to the latest frame
+// in the moving average behind GetCurrentFpsFloat. Lower values are smoother
+// but slower to follow changes, 1 reports the latest frame only.
+// Values out of range restore the default, DEFAULT_FPS_SMOOTHING.
+func (l *Loop) SetFpsSmoothing(factor float64) *Loop {
+	if factor <= 0 || factor > 1 {
+		factor = DEFAULT_FPS_SMOOTHING
+	}
+	l.fpsSmoothing = factor
+	return l
+}
 
-			if l.update != nil {
-				// Call update with delta time
-				l.update(time.Since(lastFrame))
-			}
+func (l *Loop) GetFpsSmoothing() float64 {
+	return l.fpsSmoothing
+}
 
-			if l.render != nil {
-				l.render()
-			}
+// SetMaxFrames makes every run stop once it ran n frames, e.g. for demos and tests.
+// Zero, the default, runs until stopped.
+func (l *Loop) SetMaxFrames(n int) *Loop {
+	l.maxFrames = max(n, 0)
+	return l
+}
 
-			// Frame finished timestamp (input, update, render are done)
-			lastFrame = time.Now()
-			frameCounter++
+func (l *Loop) GetMaxFrames() int {
+	return l.maxFrames
+}
 
-			if time.Since(lastSecond).Seconds() >= 1 {
-				l.currentFps = frameCounter
-				lastSecond = time.Now()
-				frameCounter = 0
-			}
+// SetWarmupFrames makes the fps and frame time stats ignore the first n frames
+// of every run, e.g. to leave out lazy allocations and asset loads. The loop
+// runs normally during warmup. Zero disables the frame warmup.
+func (l *Loop) SetWarmupFrames(n int) *Loop {
+	l.warmupFrames = max(n, 0)
+	return l
+}
 
-			sleepTime := int64(l.msPerFrame) - time.Since(start).Milliseconds()
-			if sleepTime > 0 {
-				time.Sleep(time.Duration(sleepTime) * time.Millisecond)
-			}
-		}
+func (l *Loop) GetWarmupFrames() int {
+	return l.warmupFrames
+}
+
+// SetWarmupDuration behaves like SetWarmupFrames for the first d of every run.
+// With both set, the warmup lasts until both have passed.
+func (l *Loop) SetWarmupDuration(d time.Duration) *Loop {
+	l.warmupDuration = max(d, 0)
+	return l
+}
+
+func (l *Loop) GetWarmupDuration() time.Duration {
+	return l.warmupDuration
+}
+
+// GetCurrentFpsFloat returns the fps from an exponential moving average of the
+// time between frame starts, a smoother readout than GetCurrentFps that is
+// updated every frame. See SetFpsSmoothing.
+func (l *Loop) GetCurrentFpsFloat() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.currentFpsFloat
+}
+
+// GetCurrentFps returns the number of frames run per second, as of the last fps sample.
+// When the render fps is set, this is the rate of the faster of update and render,
+// use GetCurrentUpdateFps and GetCurrentRenderFps for the rate of each.
+func (l *Loop) GetCurrentFps() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.fps()
+}
+
+// GetFrameCount returns the number of frames run since the loop last started
+func (l *Loop) GetFrameCount() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.frameCount
+}
+
+// GetDroppedFrames returns the number of frames that skipped render
+// to catch up since the loop last started
+func (l *Loop) GetDroppedFrames() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.droppedFrames
+}
+
+// GetDroppedRenders returns the number of renders dropped since the loop last
+// started because the previous render was still busy, see SetRenderAsyncDrop
+func (l *Loop) GetDroppedRenders() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.droppedRenders
+}
+
+// GetLastDelta returns the delta time handed to the most recent update, after
+// smoothing, the time scale and the max delta time. It's kept after the loop
+// stops, e.g. for crash reports, until the next run starts.
+func (l *Loop) GetLastDelta() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lastDelta
+}
+
+// GetLastRawDelta returns the real time elapsed behind the most recent update,
+// before smoothing, the time scale and the max delta time, or the time fed to
+// the accumulator in fixed timestep mode
+func (l *Loop) GetLastRawDelta() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lastRawDelta
+}
+
+// LastFrameHadHeadroom reports whether the last frame finished within its budget
+// and slept, e.g. to run optional work only on frames with slack. It's false for
+// frames that overran, and for loops that don't sleep, like uncapped or step mode.
+func (l *Loop) LastFrameHadHeadroom() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lastSleep > 0
+}
 
+// LastSleepDuration returns the time the loop slept after the last frame
+func (l *Loop) LastSleepDuration() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lastSleep
+}
+
+// GetElapsed returns the time the loop has been running since it last started,
+// as of the end of the last frame
+func (l *Loop) GetElapsed() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.elapsed
+}
+
+// GetCurrentUpdateFps returns the number of update calls per second, as of the last fps sample
+func (l *Loop) GetCurrentUpdateFps() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.currentUpdateFps
+}
+
+// GetCurrentRenderFps returns the number of render calls per second, as of the last fps sample
+func (l *Loop) GetCurrentRenderFps() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.currentRenderFps
+}
+
+// GetFrameTimeStats returns the min, max, average and standard deviation of the
+// time between consecutive frame starts, as of the last fps sample
+func (l *Loop) GetFrameTimeStats() (min, max, avg, stddev time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := l.frameTimeStats
+	return stats.Min, stats.Max, stats.Avg, stats.StdDev
+}
+
+// TimeRemaining returns how much of the frame budget is left, or zero or less
+// when the frame is over budget, e.g. to run optional work only when there's
+// slack. It's only meaningful from inside a callback while a frame is in
+// progress, and always zero when the loop is uncapped or not running.
+func (l *Loop) TimeRemaining() time.Duration {
+	l.mu.Lock()
+	running, frameStart, framePeriod, uncapped := l.isRunning, l.frameStart, l.framePeriod, l.isUncapped
+	l.mu.Unlock()
+
+	if !running || uncapped {
+		return 0
 	}
+	return l.frameBudget(framePeriod) - l.clock.Since(frameStart)
+}
+
+// IsRunning reports whether the loop is running and no stop was requested.
+// A loop is either running, stopping or stopped, see IsStopping.
+func (l *Loop) IsRunning() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.isRunning
+}
+
+// IsStopping reports whether a stop was requested but the loop hasn't exited yet,
+// e.g. while it runs the final frame of StopAndDrain. A stopping loop isn't
+// stopped yet: starting or resetting it returns ErrRunning until Done is closed.
+func (l *Loop) IsStopping() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.isStopping
+}
+
+// Pause makes the loop skip update and render while it keeps pacing frames.
+// GetCurrentFps keeps reporting the rate of paused frames, which stays close
+// to the target fps since the loop doesn't stop ticking. Stopping the loop
+// also resumes it, so every run starts unpaused.
+func (l *Loop) Pause() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.isPaused {
+		l.pausedSince = l.clock.Now()
+	}
+	l.isPaused = true
+}
+
+// Resume continues a paused loop. The delta time of the first update
+// after resuming does not include the time spent paused.
+func (l *Loop) Resume() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.endPause()
+	l.isPaused = false
+}
+
+func (l *Loop) IsPaused() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.isPaused
+}
+
+// GetPausedDuration returns the total time the loop spent paused since it last
+// started, including the pause going on, e.g. to tell active play time from
+// wall time. It's kept after the loop stops, until the next Start.
+func (l *Loop) GetPausedDuration() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.isPaused {
+		return l.pausedTotal + l.clock.Since(l.pausedSince)
+	}
+	return l.pausedTotal
+}
+
+// endPause adds the pause going on, if any, to the paused time, mu must be held
+func (l *Loop) endPause() {
+	if l.isPaused {
+		l.pausedTotal += l.clock.Since(l.pausedSince)
+	}
+}
+
+// SetInputWhilePaused sets whether the input function keeps running while the loop is paused
+func (l *Loop) SetInputWhilePaused(input bool) *Loop {
+	l.inputWhilePaused = input
+	return l
+}
+
+// SetUpdateFunc sets the update function, registered as the DEFAULT_SYSTEM system.
+// Like the input, render and recover setters, it's safe to call while the loop runs
+// and the new function is used from the next frame on, never mid-frame.
+func (l *Loop) SetUpdateFunc(update UpdateFunc) *Loop {
+	if update == nil {
+		return l.RemoveSystem(DEFAULT_SYSTEM)
+	}
+	return l.AddSystem(DEFAULT_SYSTEM, update)
+}
+
+// SetUpdateFuncErr behaves like SetUpdateFunc, but when update returns an error
+// the loop stops like Stop from a callback and Start returns that error.
+// SetUpdateFunc and its variants all set the default system,
+// so the last one called replaces the others.
+func (l *Loop) SetUpdateFuncErr(update UpdateFuncErr) *Loop {
+	return l.addSystem(system{name: DEFAULT_SYSTEM, updateErr: update})
+}
+
+// SetUpdateFuncRealScaled behaves like SetUpdateFunc, but update receives both
+// the scaled delta time and the real one, before the time scale, e.g. for UI
+// animations that ignore slow motion. In fixed timestep mode, the accumulator
+// consumes scaled time, so the scaled delta is the fixed timestep and the real
+// delta is the real time it stands for, the timestep divided by the time scale.
+func (l *Loop) SetUpdateFuncRealScaled(update UpdateFuncRealScaled) *Loop {
+	return l.addSystem(system{name: DEFAULT_SYSTEM, realScaled: update})
+}
+
+// SetLateUpdateFunc sets a function called after update and before render
+// on every frame that updated, receiving the same delta time
+func (l *Loop) SetLateUpdateFunc(lateUpdate UpdateFunc) *Loop {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lateUpdate = lateUpdate
+	return l
+}
+
+func (l *Loop) SetInputFunc(input InputFunc) *Loop {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.input, l.inputBool = input, nil
+	return l
+}
+
+// SetInputFuncBool behaves like SetInputFunc, but when input returns false the
+// loop stops and the frame ends right away, skipping its update and render,
+// e.g. on a window close event. It replaces the function set by SetInputFunc.
+func (l *Loop) SetInputFuncBool(input InputFuncBool) *Loop {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.input, l.inputBool = nil, input
+	return l
+}
+
+// SetRenderFunc sets the render function of the default render layer,
+// a nil render removes it
+func (l *Loop) SetRenderFunc(render RenderFunc) *Loop {
+	if render == nil {
+		return l.RemoveRenderLayer(DEFAULT_RENDER_LAYER)
+	}
+	return l.AddRenderLayer(DEFAULT_RENDER_LAYER, render)
+}
+
+// SetRenderFuncAlpha sets a render function that receives the interpolation alpha,
+// the progress in [0, 1) towards the next fixed update, computed as the leftover
+// accumulator divided by the fixed timestep. Alpha is always 0 outside of fixed
+// timestep mode, or when the interpolation mode skips it, see SetInterpolation.
+// When set, it's called instead of the render layers.
+func (l *Loop) SetRenderFuncAlpha(render RenderFuncAlpha) *Loop {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.renderAlpha = render
+	return l
+}
+
+func (l *Loop) SetRecoverFunc(recover RecoverFunc) *Loop {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.recoverFunc = recover
+	return l
+}
+
+// SetRecoverFuncDecide sets a function called with any panic escaping a frame,
+// returning true to carry on with the next frame or false to stop the loop, in
+// which case Start returns nil. Without it the recover function is only told
+// about the panic, and the loop stops. With SetIsolatePanics, the panics of
+// input, update and render go to the recover function and never reach it,
+// so it only sees the panics isolation doesn't cover, e.g. in the frame hooks,
+// or every panic while isolation is off.
+func (l *Loop) SetRecoverFuncDecide(recover RecoverDecideFunc) *Loop {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.recoverDecide = recover
+	return l
+}
+
+// SetIsolatePanics makes the loop recover panics in input, update and render
+// on every call, passing a PanicInfo to the recover function and carrying on
+// with the next frame. It requires a recover function, otherwise panics
+// unwind the loop as usual. A loop that keeps panicking is stopped by the
+// panic limit, see SetPanicLimit.
+func (l *Loop) SetIsolatePanics(isolate bool) *Loop {
+	l.isolatePanics = isolate
+	return l
+}
+
+// SetPanicLimit stops the loop once n isolated panics were recovered within
+// window, DEFAULT_MAX_PANICS within DEFAULT_PANIC_WINDOW by default, so a bug
+// panicking every frame doesn't spin forever. Start then returns ErrPanicLimit.
+// A zero or negative n or window disables the limit.
+func (l *Loop) SetPanicLimit(n int, window time.Duration) *Loop {
+	l.maxPanics = max(n, 0)
+	l.panicWindow = max(window, 0)
+	return l
+}
+
+func (l *Loop) GetPanicLimit() (int, time.Duration) {
+	return l.maxPanics, l.panicWindow
+}
+
+// SetOnPanicLimit sets a function called with the last recovered panic
+// when the panic limit stops the loop
+func (l *Loop) SetOnPanicLimit(onPanicLimit PanicLimitFunc) *Loop {
+	l.onPanicLimit = onPanicLimit
+	return l
+}
+
+// SetStatsFunc sets a function called once per frame with the frame timing statistics,
+// right after the sleep time of the frame has been computed
+func (l *Loop) SetStatsFunc(stats StatsFunc) *Loop {
+	l.statsFunc = stats
+	return l
+}
+
+// SetOnStart sets a function called on the loop goroutine right before the first frame
+// of every run, on the same goroutine as update
+func (l *Loop) SetOnStart(onStart HookFunc) *Loop {
+	l.onStart = onStart
+	return l
+}
+
+// SetZeroFirstDelta makes the first frame of every run measure its delta time
+// from the end of the start hook rather than from the start of the run, so slow
+// startup work doesn't hand the first update a huge delta, or a burst of fixed
+// updates. The first delta is then zero, or close to it with the real clock.
+// Off by default.
+func (l *Loop) SetZeroFirstDelta(zero bool) *Loop {
+	l.zeroFirstDelta = zero
+	return l
+}
+
+func (l *Loop) IsZeroFirstDelta() bool {
+	return l.zeroFirstDelta
+}
+
+// SetOnStop sets a function called on the loop goroutine once the loop exits,
+// whether it was stopped, its context was cancelled or a panic was recovered
+func (l *Loop) SetOnStop(onStop HookFunc) *Loop {
+	l.onStop = onStop
+	return l
+}
+
+// SetOnFirstUpdate sets a function called on the loop goroutine right before the
+// first update of every run. Unlike the start hook, the frame timing is already
+// established by then, so the first update receives a regular delta time.
+func (l *Loop) SetOnFirstUpdate(onFirstUpdate HookFunc) *Loop {
+	l.onFirstUpdate = onFirstUpdate
+	return l
+}
+
+// SetOnFrameOverrun sets a function called whenever a frame takes longer than
+// the frame budget, with how much the budget was exceeded. The time spent in it
+// isn't part of the overrun frame, but counts towards the next frame's delta time.
+func (l *Loop) SetOnFrameOverrun(onFrameOverrun OverrunFunc) *Loop {
+	l.onFrameOverrun = onFrameOverrun
+	return l
+}
+
+// SetOnBeforeSleep sets a function called right before the loop sleeps for the
+// rest of a frame, with the time it's about to sleep, e.g. to yield to other
+// work or hint the garbage collector during the idle gap. It's only called
+// when there's time to sleep. The time spent in it isn't part of the frame,
+// it's taken from the sleep instead, so the loop keeps its pace.
+func (l *Loop) SetOnBeforeSleep(onBeforeSleep SleepFunc) *Loop {
+	l.onBeforeSleep = onBeforeSleep
+	return l
+}
+
+// SetOnFpsSample sets a function called every time the current fps is sampled,
+// once per fps sample window, with the newly computed fps
+func (l *Loop) SetOnFpsSample(onFpsSample FpsFunc) *Loop {
+	l.onFpsSample = onFpsSample
+	return l
+}
+
+// SetBeforeFrame sets a function called at the very start of every frame,
+// before input, e.g. for telemetry or resetting per-frame state. Its time
+// counts towards the frame time and budget. It's safe to call while running.
+func (l *Loop) SetBeforeFrame(beforeFrame FrameHookFunc) *Loop {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.beforeFrame = beforeFrame
+	return l
+}
+
+// SetAfterFrame sets a function called at the very end of every frame, after
+// the sleep, with the total delta time of the frame. Its time is excluded from
+// the frame time, but delays the start of the next frame, so keep it short.
+// It's safe to call while running.
+func (l *Loop) SetAfterFrame(afterFrame FrameHookFunc) *Loop {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.afterFrame = afterFrame
+	return l
+}
+
+// SetOnWarmupComplete sets a function called once the warmup of a run ends,
+// right before the first frame counted by the fps sample
+func (l *Loop) SetOnWarmupComplete(onWarmupDone HookFunc) *Loop {
+	l.onWarmupDone = onWarmupDone
+	return l
+}
+
+// SetLockOSThread makes the loop goroutine lock itself to its OS thread while running,
+// so that every callback runs on the same thread, as required by e.g. OpenGL contexts
+func (l *Loop) SetLockOSThread(lock bool) *Loop {
+	l.lockOSThread = lock
+	return l
+}
+
+// SetOnSustainedOverrun sets a function called when a fps sample drops below
+// threshold * target fps, in [0, 1], with the sampled fps. Unlike the frame overrun
+// hook, it reflects the loop being unable to keep up over a whole sample window.
+// Once fired, it only fires again after the fps recovers to above the threshold
+// plus SUSTAINED_OVERRUN_HYSTERESIS.
+func (l *Loop) SetOnSustainedOverrun(threshold float64, onSustainedOverrun FpsFunc) *Loop {
+	l.sustainedOverrunThreshold = min(max(threshold, 0), 1)
+	l.onSustainedOverrun = onSustainedOverrun
+	return l
+}
+
+// SetOnSpiral sets a function called once the loop overran its frame budget for
+// the spiral frames in a row, DEFAULT_SPIRAL_FRAMES by default, a sign the loop is
+// falling into a spiral of death. It gives the game a chance to shed load, e.g. by
+// lowering the target fps. By default the loop also recovers by dropping the fixed
+// update backlog, see SetSpiralRecovery. Detection is off while no hook is set.
+func (l *Loop) SetOnSpiral(onSpiral HookFunc) *Loop {
+	l.onSpiral = onSpiral
+	return l
+}
+
+// SetSpiralFrames sets how many consecutive overrun frames fire the spiral hook
+func (l *Loop) SetSpiralFrames(n int) *Loop {
+	l.spiralFrames = max(n, 1)
+	return l
+}
+
+func (l *Loop) GetSpiralFrames() int {
+	return l.spiralFrames
+}
+
+// SetSpiralRecovery sets whether the loop resets the fixed timestep accumulator
+// when the spiral hook fires, dropping the update backlog. On by default.
+func (l *Loop) SetSpiralRecovery(recovery bool) *Loop {
+	l.spiralRecovery = recovery
+	return l
+}
+
+// Start attempts to start the game loop.
+// It requires an update function to be set and a valid configuration,
+// see Validate, and blocks until the loop is stopped.
+// A stopped loop can be started again once it exited, see Done,
+// before that Start returns ErrRunning.
+func (l *Loop) Start() error {
+	return l.StartContext(context.Background())
+}
+
+// StartContext behaves like Start, but also stops the loop when ctx is done,
+// in which case it returns ctx.Err().
+func (l *Loop) StartContext(ctx context.Context) error {
+	started, err := l.begin()
+	if err != nil || !started {
+		return err
+	}
+
+	return l.runStarted(ctx)
+}
+
+// StartAsync runs the loop in its own goroutine and returns right away with
+// any configuration error, or ErrRunning if the loop is running or still stopping.
+// Once the loop exits, the error Start would have returned is sent on done.
+func (l *Loop) StartAsync() (done <-chan error, err error) {
+	started, err := l.begin()
+	if err != nil {
+		return nil, err
+	}
+
+	if !started {
+		return nil, ErrRunning
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- l.runStarted(context.Background())
+	}()
+
+	return result, nil
+}
+
+// RunFrames runs the loop like Start, but stops it once it ran exactly n frames,
+// overriding SetMaxFrames for this run. Frames are paced as usual, so with a fake
+// clock the run is fully deterministic. It returns ErrRunning if running or still stopping.
+// An n of zero or less runs no frame and returns nil right away, without starting.
+func (l *Loop) RunFrames(n int) error {
+	if n <= 0 {
+		return nil
+	}
+
+	started, err := l.begin()
+	if err != nil {
+		return err
+	}
+
+	if !started {
+		return ErrRunning
+	}
+
+	l.mu.Lock()
+	l.runFrames = n
+	l.mu.Unlock()
+
+	return l.runStarted(context.Background())
+}
+
+// RunUntil runs the loop like Start, but stops it once the loop clock reaches
+// deadline. The frame running at the deadline completes, and the sleep before
+// a frame that would start past it is cut short. It returns nil when stopped
+// by the deadline, and ErrRunning if running or still stopping.
+func (l *Loop) RunUntil(deadline time.Time) error {
+	started, err := l.begin()
+	if err != nil {
+		return err
+	}
+
+	if !started {
+		return ErrRunning
+	}
+
+	l.mu.Lock()
+	l.runUntil = deadline
+	l.mu.Unlock()
+
+	return l.runStarted(context.Background())
+}
+
+// RunFor runs the loop like RunUntil, for d from now on the loop clock
+func (l *Loop) RunFor(d time.Duration) error {
+	return l.RunUntil(l.clock.Now().Add(d))
+}
+
+// begin validates the config and marks the loop as running,
+// it returns false when the loop was already running, and ErrRunning
+// while a stopped run is still finishing, which owns the run state until it exits
+func (l *Loop) begin() (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.validate(); err != nil {
+		return false, err
+	}
+
+	if l.isRunning {
+		return false, nil
+	}
+
+	if l.isStopping {
+		return false, ErrRunning
+	}
+
+	// Every run gets a fresh stop channel so a stopped loop can be started again
+	l.stopCh = make(chan struct{})
+	select {
+	case <-l.doneCh:
+		l.doneCh = make(chan struct{})
+	default:
+	}
+	l.once = sync.Once{}
+	l.isRunning = true
+	l.isDraining = false
+	l.frameCount = 0
+	l.droppedFrames = 0
+	l.droppedRenders = 0
+	l.elapsed = 0
+	l.lastFrameTime = 0
+	l.stopErr = nil
+	l.qualityTier = 0
+	l.lastDelta = 0
+	l.lastRawDelta = 0
+	l.pausedTotal = 0
+	l.pausedSince = l.clock.Now()
+	l.lastSleep = 0
+	l.accumulator = 0
+	l.histogram.reset()
+	l.droppedFrameEvents = 0
+	for _, sys := range l.systems {
+		if sys.rate != nil {
+			sys.rate.reset()
+		}
+	}
+	l.frameTimeStats = FrameTimeStats{}
+	return true, nil
+}
+
+// runStarted runs a loop marked as running by begin until it stops
+func (l *Loop) runStarted(ctx context.Context) (err error) {
+	defer func() {
+		l.mu.Lock()
+		l.isRunning = false
+		l.isStopping = false
+		l.endPause()
+		l.isPaused = false
+		l.runFrames = 0
+		l.runUntil = time.Time{}
+		l.startedCh = make(chan struct{})
+		close(l.doneCh)
+		l.mu.Unlock()
+	}()
+
+	// The stop is logged after the panic that ended the run, if any
+	var panicked any
+	defer func() {
+		l.logStop(err, panicked)
+	}()
+
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = r
+			l.mu.Lock()
+			recoverFunc := l.recoverFunc
+			l.mu.Unlock()
+
+			if recoverFunc == nil {
+				panic(r)
+			}
+			recoverFunc(r)
+			l.logPanic(r)
+		}
+	}()
+
+	if l.inputOnMainThread {
+		return l.runOnWorker(ctx)
+	}
+	return l.run(ctx, nil)
+}
+
+// StartOnCurrentThread behaves like Start, but locks the calling goroutine to its
+// current OS thread for the whole run, e.g. the main thread that created a window.
+// Since it blocks that thread, the loop must be stopped from another goroutine
+// or from inside one of its callbacks.
+func (l *Loop) StartOnCurrentThread() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	return l.Start()
+}
+
+// Stop attempts to stop the game loop by sending a stop signal.
+// Calling it more than once, or on a loop that is not running, is a no-op.
+// When called from a callback, the current frame still completes including
+// render, but it runs no further fixed updates and skips the frame sleep.
+func (l *Loop) Stop() error {
+	if err := l.StopStrict(); !errors.Is(err, ErrNotRunning) {
+		return err
+	}
+	return nil
+}
+
+// StopTimeout stops the loop like Stop and waits until it exited, returning
+// ErrStopTimeout if it's still running after d, e.g. because a callback hangs
+// on I/O. The loop can't be killed, so its goroutine leaks for as long as the
+// callback stays stuck. The timeout is measured in real time, whatever the loop
+// clock. Calling it from a callback of the loop always times out.
+func (l *Loop) StopTimeout(d time.Duration) error {
+	l.mu.Lock()
+	done, running := l.doneCh, l.isRunning || l.isStopping
+	err := l.stop(false)
+	l.mu.Unlock()
+
+	if !running {
+		return nil
+	}
+
+	if err != nil && !errors.Is(err, ErrNotRunning) {
+		return err
+	}
+
+	timeout := time.NewTimer(d)
+	defer timeout.Stop()
+
+	select {
+	case <-done:
+		return nil
+	case <-timeout.C:
+		return ErrStopTimeout
+	}
+}
+
+// StopStrict behaves like Stop, but returns ErrNotRunning
+// when the loop is not running
+func (l *Loop) StopStrict() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stop(false)
+}
+
+// stop signals the loop to stop, optionally after a drain frame, mu must be held.
+// Only the first call of a run has any effect, so concurrent stops coalesce
+// into a single shutdown and the stop channel is closed exactly once.
+func (l *Loop) stop(drain bool) error {
+	if !l.isRunning {
+		return ErrNotRunning
+	}
+
+	l.isRunning = false
+	l.isStopping = true
+	l.isDraining = drain
+	l.once.Do(func() {
+		close(l.stopCh)
+	})
+	return nil
+}
+
+// stopWithError stops the loop and makes Start return err,
+// unless an earlier error already stopped it
+func (l *Loop) stopWithError(err error) {
+	l.mu.Lock()
+	if l.stopErr == nil {
+		l.stopErr = err
+	}
+	l.mu.Unlock()
+
+	l.Stop()
+}
+
+// stopError returns the error that stopped the current run, if any
+func (l *Loop) stopError() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stopErr
+}
+
+// StopAndDrain stops the game loop like Stop, but the loop runs one last full
+// input, update and render cycle before Start returns, e.g. to save on exit.
+// The final frame runs in full even while paused, e.g. to draw a paused frame
+// on exit, with the delta time since the last paused frame.
+func (l *Loop) StopAndDrain() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.stop(true); !errors.Is(err, ErrNotRunning) {
+		return err
+	}
+	return nil
+}
+
+// Started returns a channel that is closed once the loop has started
+// and is about to run its first frame. After the loop stops,
+// it returns a new channel for the next run.
+func (l *Loop) Started() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.startedCh
+}
+
+// Done returns a channel that is closed once the loop exits, however it exited.
+// After the loop stops, it stays closed until the next run starts, which gets
+// a new channel. Before the first run, it returns the channel for that run.
+func (l *Loop) Done() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.doneCh
+}
+
+// WaitUntilRunning blocks until the loop is about to run its first frame,
+// and reports whether that happened within timeout
+func (l *Loop) WaitUntilRunning(timeout time.Duration) bool {
+	select {
+	case <-l.Started():
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// runState holds the timing values carried across the frames of a single run
+type runState struct {
+	runStart      time.Time
+	frameCounter  int
+	updateCounter int
+	renderCounter int
+	lastFrame     time.Time
+	lastSecond    time.Time
+	lastStart     time.Time
+	accumulator   time.Duration
+
+	// Next due times when update, render and input run on separate rates
+	nextUpdate time.Time
+	nextRender time.Time
+	nextInput  time.Time
+
+	// Set for the final frame run by StopAndDrain, which skips the sleep
+	final bool
+
+	// Delta time of the frame run by Step, zero for regular frames
+	stepDelta time.Duration
+
+	// Consecutive frames that skipped render to catch up
+	renderSkips int
+
+	// Set once the sustained overrun hook fired, until the fps recovers
+	sustainedOverrun bool
+
+	// Recent variable deltas used for delta smoothing
+	deltas    deltaWindow
+	deltaAvg  deltaEma
+	wasPaused bool
+
+	// Runs render on its own goroutine when concurrent render is on
+	renderer *renderer
+
+	// Runs input on the goroutine that started the loop, see SetInputOnMainThread
+	mainThread *mainThread
+
+	// Times between frame starts in the current fps sample window
+	frameTimes frameTimes
+	ranFrame   bool
+
+	// Set until the warmup completes
+	warmingUp bool
+
+	// Time the run stops at, none when zero
+	deadline time.Time
+
+	// Set once the first update of the run is about to be called
+	updated bool
+
+	// Per system update times of the current frame, reused across frames
+	systemTimes []SystemStats
+
+	// Recovers isolated panics, counting them towards the panic limit,
+	// and the times of the panics within the current panic window
+	isolated RecoverFunc
+	panics   []time.Time
+
+	// Frames to run before stopping, unlimited when zero
+	maxFrames uint64
+
+	// Consecutive frames that overran their budget
+	overruns int
+
+	// Delta time of the last frame's updates, handed to render when it runs first
+	renderDelta time.Duration
+
+	// Context handed to deadline updates, reset to the frame deadline every frame
+	frameCtx *frameContext
+
+	// Scheduled start of the current frame, which the next one is paced from,
+	// so sleeping late is made up for instead of adding up over the frames.
+	// Zero when the frame wasn't paced, the next one is then paced from its start.
+	frameTarget time.Time
+
+	// Time spent in the frame phases in the current fps sample window
+	workTime time.Duration
+
+	// Moving average of the time between frame starts, in nanoseconds
+	avgFrameTime float64
+}
+
+func (l *Loop) run(ctx context.Context, thread *mainThread) error {
+	now := l.clock.Now()
+	state := &runState{
+		runStart:   now,
+		lastFrame:  now,
+		lastSecond: now,
+		lastStart:  now,
+		nextUpdate: now,
+		nextRender: now,
+		nextInput:  now,
+		warmingUp:  l.warmupFrames > 0 || l.warmupDuration > 0,
+		maxFrames:  uint64(l.maxFrames),
+		mainThread: thread,
+		frameCtx:   &frameContext{clock: l.clock},
+	}
+	if l.runFrames > 0 {
+		state.maxFrames = uint64(l.runFrames)
+	}
+	state.deadline = l.runUntil
+	state.isolated = func(r any) {
+		l.isolatedPanic(state, r)
+	}
+
+	if l.lockOSThread {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+	}
+
+	if l.onStop != nil {
+		defer l.onStop()
+	}
+
+	if l.concurrentRender || l.renderAsyncDrop {
+		state.renderer = newRenderer()
+		defer state.renderer.stop()
+	}
+
+	if l.onStart != nil {
+		l.onStart()
+	}
+	l.pollTargetFps()
+	l.logStart()
+
+	if l.zeroFirstDelta {
+		// The first frame doesn't pay for the time spent starting up
+		state.resync(l.clock.Now())
+	}
+
+	l.mu.Lock()
+	close(l.startedCh)
+	l.mu.Unlock()
+
+	for {
+		if l.IsStepMode() {
+			select {
+			case <-ctx.Done():
+				return l.cancel(ctx)
+			case <-l.stopCh:
+				l.drain(state)
+				return l.stopError()
+			case <-l.wakeCh:
+				// Leaving step mode, the time spent waiting for steps isn't part of the next delta
+				state.resync(l.clock.Now())
+			case step := <-l.stepCh:
+				if ctx.Err() != nil {
+					// The context was cancelled while the step was pending
+					step.done <- ErrNotRunning
+					return l.cancel(ctx)
+				}
+				l.step(state, step)
+			}
+			continue
+		}
+
+		if l.tickSource != nil {
+			select {
+			case <-ctx.Done():
+				return l.cancel(ctx)
+			case <-l.stopCh:
+				l.drain(state)
+				return l.stopError()
+			case _, ok := <-l.tickSource:
+				if !ok {
+					// A closed tick source will never drive another frame
+					l.Stop()
+					continue
+				}
+				l.runFrame(state)
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return l.cancel(ctx)
+		case <-l.stopCh:
+			l.drain(state)
+			return l.stopError()
+		default:
+			l.runFrame(state)
+		}
+	}
+}
+
+// cancel stops the run when its context is done, so that pending calls
+// waiting on the stop channel, like Step, see the loop stop too
+func (l *Loop) cancel(ctx context.Context) error {
+	l.mu.Lock()
+	l.stop(false)
+	l.mu.Unlock()
+	return ctx.Err()
+}
+
+// drain runs the final frame requested by StopAndDrain, if any
+func (l *Loop) drain(s *runState) {
+	l.mu.Lock()
+	draining := l.isDraining
+	l.mu.Unlock()
+
+	if draining {
+		s.final = true
+		l.runFrame(s)
+	}
+}
+
+// runFrame runs a frame, handing a panic escaping it to the recover decide
+// function if set, which decides whether the loop carries on or stops
+func (l *Loop) runFrame(s *runState) {
+	l.mu.Lock()
+	decide := l.recoverDecide
+	l.mu.Unlock()
+
+	if decide == nil {
+		l.frame(s)
+		return
+	}
+
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		l.logPanic(r)
+		if !decide(r) {
+			l.Stop()
+			return
+		}
+
+		// The panicked frame didn't finish, the next one starts over from now
+		s.resync(l.clock.Now())
+	}()
+	l.frame(s)
+}
+
+// resync moves the frame timestamps to now, so the time before it isn't handed to update
+func (s *runState) resync(now time.Time) {
+	s.lastFrame = now
+	s.lastStart = now
+	s.nextUpdate = now
+	s.nextRender = now
+	s.nextInput = now
+	s.frameTarget = time.Time{}
+}
+
+// frame runs a single input, update and render cycle and then sleeps for the rest of the frame time
+func (l *Loop) frame(s *runState) {
+	var stats FrameStats
+	start := l.clock.Now()
+	interval, hasInterval := start.Sub(s.lastStart), s.ranFrame
+	if hasInterval {
+		s.frameTimes.add(interval)
+	}
+	s.ranFrame = true
+	l.sampleFps(s, start)
+	if hasInterval && !s.warmingUp {
+		l.smoothFps(s, interval)
+		if l.fpsCounter != nil || l.collectHistogram {
+			l.mu.Lock()
+			if l.fpsCounter != nil {
+				l.fpsCounter.Tick(interval)
+			}
+			if l.collectHistogram {
+				l.histogram.add(interval)
+			}
+			l.mu.Unlock()
+		}
+	}
+
+	l.mu.Lock()
+	l.frameStart = start
+	framePeriod := l.framePeriod
+	// The final frame of StopAndDrain runs in full, paused or not
+	paused, uncapped := l.isPaused && !s.final, l.isUncapped
+	timeScale, deltaSource := l.timeScale, l.deltaSource
+	input, inputBool, systems, lateUpdate := l.input, l.inputBool, l.systems, l.lateUpdate
+	layers, renderAlpha, renderFrame, present := l.layers, l.renderAlpha, l.renderFrame, l.onPresent
+	renderFirst := l.renderFirst
+	info := Frame{
+		Number:  l.frameCount,
+		Elapsed: start.Sub(s.runStart),
+		Fps:     l.fps(),
+	}
+	recoverFunc := l.recoverFunc
+	beforeFrame, afterFrame := l.beforeFrame, l.afterFrame
+	if l.hasAccumulator {
+		s.accumulator, l.hasAccumulator = l.pendingAccumulator, false
+	}
+	var isolated RecoverFunc
+	if l.isolatePanics && recoverFunc != nil {
+		isolated = s.isolated
+	}
+	l.mu.Unlock()
+
+	if l.stopping() {
+		isolated = l.shutdownRecover(isolated, recoverFunc)
+	}
+
+	// Deadline updates get until the end of the frame budget
+	var deadline time.Time
+	if !uncapped {
+		deadline = start.Add(l.frameBudget(framePeriod))
+	}
+	s.frameCtx.reset(deadline)
+
+	if beforeFrame != nil {
+		beforeFrame(info)
+	}
+
+	if afterFrame != nil {
+		defer func() {
+			info.Delta, info.Alpha = stats.Delta, l.alpha(s.accumulator, framePeriod)
+			afterFrame(info)
+		}()
+	}
+
+	// With separate render or input rates, variable updates, renders and input polls
+	// only run once due. Fixed updates are already paced by the accumulator.
+	updateDue, renderDue, inputDue := true, true, true
+	if (l.renderFps > 0 || l.inputFps > 0) && s.stepDelta == 0 {
+		if l.fixedTimestep == 0 {
+			updateDue = nextDue(&s.nextUpdate, start, framePeriod)
+		}
+		if l.renderFps > 0 {
+			renderDue = nextDue(&s.nextRender, start, l.renderPeriod)
+		}
+		if l.inputFps > 0 {
+			inputDue = nextDue(&s.nextInput, start, l.inputPeriod)
+		}
+	}
+
+	if (input != nil || inputBool != nil) && inputDue && (!paused || l.inputWhilePaused) {
+		phaseStart := l.clock.Now()
+		var keepRunning bool
+		if s.mainThread != nil {
+			keepRunning = s.mainThread.pollInput(input, inputBool, isolated)
+		} else {
+			keepRunning = pollInput(input, inputBool, isolated)
+		}
+		stats.Input = l.clock.Since(phaseStart)
+
+		if !keepRunning {
+			// Quit right away, without running update and render first
+			l.Stop()
+			return
+		}
+	}
+
+	// While paused the frame timestamps keep moving, so the paused
+	// time is never handed to update once the loop resumes
+	if s.wasPaused && !paused {
+		s.deltas.reset()
+		s.deltaAvg.reset()
+	}
+	s.wasPaused = paused
+
+	// Set when fixed updates had to catch up on more than one step this frame
+	behind := false
+
+	job := renderJob{
+		layers:      layers,
+		renderAlpha: renderAlpha,
+		renderFrame: renderFrame,
+		present:     present,
+		isolated:    isolated,
+	}
+
+	if !paused {
+		if renderFirst {
+			// Render the state left by the previous frame's updates first
+			job.frame = info
+			l.renderPhase(s, job, renderDue, false, s.renderDelta, recoverFunc, framePeriod, &stats)
+		}
+
+		if len(systems) > 0 && updateDue {
+			times := l.systemStats(s.systemTimes, systems)
+			s.systemTimes, stats.Systems = times, times
+			phaseStart := l.clock.Now()
+			if s.stepDelta > 0 {
+				// A single update with the exact step delta time
+				info.Delta, info.RealDelta = s.stepDelta, s.stepDelta
+				l.firstUpdate(s)
+				l.updateSystems(s.frameCtx, systems, info, isolated, times)
+				stats.Delta = s.stepDelta
+				s.updateCounter++
+				l.setLastDelta(s.stepDelta, s.stepDelta)
+			} else if l.fixedTimestep > 0 {
+				// Consume the real time elapsed since the last frame in fixed steps,
+				// the remainder is carried over to the next frame
+				raw := start.Sub(s.lastStart)
+				if deltaSource != nil {
+					s.accumulator += max(deltaSource(), 0)
+				} else {
+					s.accumulator += l.clampDelta(scaleDelta(raw, timeScale))
+				}
+				steps, maxSteps := 0, l.maxSteps()
+				realStep := unscaleDelta(l.fixedTimestep, timeScale)
+				if deltaSource != nil {
+					realStep = l.fixedTimestep
+				}
+				for s.accumulator >= l.fixedTimestep && (maxSteps == 0 || steps < maxSteps) && !l.stopping() {
+					info.Delta, info.RealDelta = l.fixedTimestep, realStep
+					l.firstUpdate(s)
+					l.updateSystems(s.frameCtx, systems, info, isolated, times)
+					stats.Delta += l.fixedTimestep
+					s.accumulator -= l.fixedTimestep
+					s.updateCounter++
+					steps++
+				}
+
+				if s.accumulator >= l.fixedTimestep {
+					s.accumulator %= l.fixedTimestep
+				}
+				behind = steps > 1
+				if steps > 0 {
+					l.setLastDelta(l.fixedTimestep, raw)
+				}
+			} else {
+				// Call update with delta time
+				raw := l.clock.Since(s.lastFrame)
+				delta, realDelta := raw, raw
+				if deltaSource != nil {
+					delta = max(deltaSource(), 0)
+					realDelta = delta
+				} else {
+					if l.deltaSmoothing > 1 {
+						delta = s.deltas.add(delta, l.deltaSmoothing)
+					}
+					if l.deltaEma > 0 && l.deltaEma < 1 {
+						delta = s.deltaAvg.add(delta, l.deltaEma)
+					}
+					realDelta = l.clampDelta(delta)
+					delta = l.clampDelta(scaleDelta(delta, timeScale))
+				}
+				info.Delta, info.RealDelta = delta, realDelta
+				l.firstUpdate(s)
+				l.updateSystems(s.frameCtx, systems, info, isolated, times)
+				stats.Delta = delta
+				s.updateCounter++
+				l.setLastDelta(delta, raw)
+			}
+			stats.Update = l.clock.Since(phaseStart)
+
+			if lateUpdate != nil {
+				// Runs once after every update of the frame, with the same total delta time
+				phaseStart = l.clock.Now()
+				guard(PHASE_LATE_UPDATE, isolated, func() {
+					lateUpdate(stats.Delta)
+				})
+				stats.LateUpdate = l.clock.Since(phaseStart)
+			}
+			s.renderDelta = stats.Delta
+		}
+
+		if !renderFirst {
+			job.frame = info
+			l.renderPhase(s, job, renderDue, behind, stats.Delta, recoverFunc, framePeriod, &stats)
+		}
+	}
+	s.lastStart = start
+
+	// Frame finished timestamp (input, update, render are done).
+	// Frames without a due update keep the timestamp, so the next delta covers them.
+	if updateDue || paused {
+		s.lastFrame = l.clock.Now()
+	}
+	s.frameCounter++
+	stats.Frame = l.clock.Since(start)
+	s.workTime += stats.Frame
+
+	l.mu.Lock()
+	l.frameCount++
+	l.elapsed = l.clock.Since(s.runStart)
+	l.lastFrameTime = stats.Frame
+	frameCount := l.frameCount
+	events := l.frameEvents
+	l.accumulator = s.accumulator
+	l.mu.Unlock()
+
+	if events != nil {
+		info.Delta, info.Alpha = stats.Delta, l.alpha(s.accumulator, framePeriod)
+		l.emitFrameEvent(events, info)
+	}
+
+	if s.maxFrames > 0 && frameCount >= s.maxFrames {
+		l.Stop()
+	}
+
+	if !s.deadline.IsZero() && !l.clock.Now().Before(s.deadline) {
+		l.Stop()
+	}
+
+	budget := l.frameBudget(framePeriod)
+
+	if l.tickSource != nil || s.final || s.stepDelta > 0 || l.stopping() || (uncapped && l.minFrameTime == 0) {
+		// There is no frame budget to sleep for or overrun
+		s.frameTarget = time.Time{}
+		l.setLastSleep(0)
+		l.report(stats)
+		return
+	}
+
+	if uncapped {
+		// Only sleep up to the min frame time, longer frames aren't overruns
+		s.frameTarget = time.Time{}
+		stats.Sleep = max(l.minFrameTime-stats.Frame, 0)
+		l.setLastSleep(stats.Sleep)
+		l.report(stats)
+		if stats.Sleep > 0 {
+			l.sleep(stats.Sleep)
+		}
+		return
+	}
+
+	// Sleep until the next frame is due on the frame schedule, rather than for
+	// the rest of the budget, so a frame started late sleeps that much less
+	scheduled := s.frameTarget
+	if scheduled.IsZero() || start.Sub(scheduled) >= budget {
+		scheduled = start
+	}
+	target := scheduled.Add(budget)
+
+	sleepTime := target.Sub(l.clock.Now())
+	if sleepTime > 0 {
+		stats.Sleep = sleepTime
+	}
+
+	l.setLastSleep(stats.Sleep)
+	l.report(stats)
+
+	if sleepTime > 0 {
+		s.overruns = 0
+		if !s.deadline.IsZero() && target.After(s.deadline) {
+			// The next frame would start past the deadline
+			if remaining := s.deadline.Sub(l.clock.Now()); remaining > 0 {
+				l.sleep(remaining)
+			}
+			l.Stop()
+			return
+		}
+		s.frameTarget = target
+		if remaining := target.Sub(l.clock.Now()); remaining > 0 {
+			l.sleep(remaining)
+		}
+		return
+	}
+
+	// An overrun frame restarts the schedule, the next frames don't catch up
+	s.frameTarget = time.Time{}
+	if l.onFrameOverrun != nil {
+		l.onFrameOverrun(-sleepTime)
+	}
+	l.logOverrun(frameCount, -sleepTime)
+	l.detectSpiral(s)
+}
+
+// renderPhase renders the frame once due, unless the render is dropped to let
+// updates catch up. Render is handed the delta time of the updates it follows.
+func (l *Loop) renderPhase(s *runState, job renderJob, renderDue, behind bool, delta time.Duration, recoverFunc RecoverFunc, framePeriod time.Duration, stats *FrameStats) {
+	hasRender := !l.simulationOnly && (len(job.layers) > 0 || job.renderAlpha != nil || job.renderFrame != nil)
+	if hasRender && renderDue && behind && s.renderSkips < l.maxRenderSkip {
+		// Drop the render to let updates catch up
+		renderDue = false
+		s.renderSkips++
+		l.mu.Lock()
+		l.droppedFrames++
+		l.mu.Unlock()
+	}
+
+	if !hasRender || !renderDue {
+		return
+	}
+
+	if l.stopping() {
+		job.isolated = l.shutdownRecover(job.isolated, recoverFunc)
+	}
+
+	s.renderSkips = 0
+	job.frame.Delta, job.frame.Alpha = delta, l.alpha(s.accumulator, framePeriod)
+
+	phaseStart := l.clock.Now()
+	rendered := true
+	if s.renderer != nil && l.renderAsyncDrop {
+		rendered = s.renderer.trySubmit(job)
+	} else if s.renderer != nil {
+		s.renderer.submit(job)
+	} else {
+		job.render()
+	}
+	stats.Render = l.clock.Since(phaseStart)
+
+	if s.renderer == nil && job.present != nil {
+		phaseStart = l.clock.Now()
+		job.presentFrame()
+		stats.Present = l.clock.Since(phaseStart)
+	}
+
+	if rendered {
+		s.renderCounter++
+	} else {
+		stats.RenderDropped = true
+		l.mu.Lock()
+		l.droppedRenders++
+		l.mu.Unlock()
+	}
+}
+
+// firstUpdate fires the first update hook right before the first update of the run
+func (l *Loop) firstUpdate(s *runState) {
+	if s.updated {
+		return
+	}
+
+	s.updated = true
+	if l.onFirstUpdate != nil {
+		l.onFirstUpdate()
+	}
+}
+
+// detectSpiral fires the spiral hook once the loop overran its budget for
+// spiralFrames frames in a row, and drops the fixed update backlog to recover
+func (l *Loop) detectSpiral(s *runState) {
+	if l.onSpiral == nil {
+		return
+	}
+
+	s.overruns++
+	if s.overruns < l.spiralFrames {
+		return
+	}
+
+	s.overruns = 0
+	if l.spiralRecovery {
+		s.accumulator = 0
+	}
+	l.onSpiral()
+}
+
+// report hands the frame stats to the stats function and the debug trace
+func (l *Loop) report(stats FrameStats) {
+	if l.statsFunc != nil {
+		l.statsFunc(stats)
+	}
+
+	if l.isDebugMode && l.logger != nil {
+		l.logFrame(l.frameCount, stats)
+	} else if l.isDebugMode {
+		fmt.Fprintf(l.debugWriter, "gyro: frame=%d delta=%v input=%v update=%v late_update=%v render=%v present=%v frame_time=%v sleep=%v\n",
+			l.frameCount, stats.Delta, stats.Input, stats.Update, stats.LateUpdate, stats.Render, stats.Present, stats.Frame, stats.Sleep)
+	}
+}
+
+// pollTargetFps applies the fps returned by the target fps function, if set
+func (l *Loop) pollTargetFps() {
+	if l.targetFpsFunc == nil {
+		return
+	}
+
+	fps := float64(l.targetFpsFunc())
+	if fps != l.GetTargetFpsFloat() {
+		l.SetTargetFpsFloat(fps)
+	}
+}
+
+// warmup holds the fps sample in reset until the warmup frames and duration have passed
+func (l *Loop) warmup(s *runState, now time.Time) {
+	s.resetSample(now)
+
+	l.mu.Lock()
+	frames := l.frameCount
+	l.mu.Unlock()
+
+	if frames < uint64(l.warmupFrames) || now.Sub(s.runStart) < l.warmupDuration {
+		return
+	}
+
+	s.warmingUp = false
+	if l.onWarmupDone != nil {
+		l.onWarmupDone()
+	}
+}
+
+// smoothFps folds the time since the last frame start into the moving average behind GetCurrentFpsFloat
+func (l *Loop) smoothFps(s *runState, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	if s.avgFrameTime == 0 {
+		s.avgFrameTime = float64(interval)
+	} else {
+		s.avgFrameTime += l.fpsSmoothing * (float64(interval) - s.avgFrameTime)
+	}
+
+	l.mu.Lock()
+	l.currentFpsFloat = float64(time.Second) / s.avgFrameTime
+	l.mu.Unlock()
+}
+
+// resetSample starts a new fps sample window at now
+func (s *runState) resetSample(now time.Time) {
+	s.lastSecond = now
+	s.frameCounter = 0
+	s.updateCounter = 0
+	s.renderCounter = 0
+	s.frameTimes = frameTimes{}
+	s.workTime = 0
+}
+
+// sampleFps computes the current fps once the sample window has passed, or
+// once the sample frame count was reached. It runs at the start of a frame,
+// so each window counts the frames started within it.
+func (l *Loop) sampleFps(s *runState, now time.Time) {
+	if s.warmingUp {
+		l.warmup(s, now)
+		return
+	}
+
+	elapsed := now.Sub(s.lastSecond)
+	if l.fpsSampleFrames > 0 {
+		if s.frameCounter < l.fpsSampleFrames || elapsed <= 0 {
+			return
+		}
+	} else if elapsed < l.fpsSampleWindow {
+		return
+	}
+
+	// The window can run past its duration, so scale the counts to a per-second rate
+	perSecond := func(count int) int {
+		return int(math.Round(float64(count) / elapsed.Seconds()))
+	}
+
+	fps := perSecond(s.frameCounter)
+	l.mu.Lock()
+	l.currentFps = fps
+	l.currentUpdateFps = perSecond(s.updateCounter)
+	l.currentRenderFps = perSecond(s.renderCounter)
+	l.frameTimeStats = s.frameTimes.stats()
+	targetFps, budget := l.targetFps, l.frameBudget(l.framePeriod)
+	l.mu.Unlock()
+
+	times, work, frames := l.frameTimeStats, s.workTime, s.frameCounter
+	s.resetSample(now)
+
+	if l.onFpsSample != nil {
+		l.onFpsSample(fps)
+	}
+	l.pollTargetFps()
+	l.updateQuality(times, work, frames, budget)
+
+	if l.onSustainedOverrun != nil {
+		ratio := float64(fps) / targetFps
+		if !s.sustainedOverrun && ratio < l.sustainedOverrunThreshold {
+			s.sustainedOverrun = true
+			l.onSustainedOverrun(fps)
+		} else if s.sustainedOverrun && ratio >= l.sustainedOverrunThreshold+SUSTAINED_OVERRUN_HYSTERESIS {
+			s.sustainedOverrun = false
+		}
+	}
+}
+
+// shutdownRecover returns the function recovering panics from phases that run
+// after the loop was asked to stop, e.g. a render hitting freed resources, so
+// they go to the recover function even without isolated panics. Without a
+// recover function it's nil, and the panic is rethrown by Start once the run
+// state was reset, like any other panic. Panics it recovers are logged.
+func (l *Loop) shutdownRecover(isolated, recoverFunc RecoverFunc) RecoverFunc {
+	if isolated != nil || recoverFunc == nil {
+		return isolated
+	}
+
+	return func(r any) {
+		recoverFunc(r)
+		l.logPanic(r)
+	}
+}
+
+// isolatedPanic passes an isolated panic to the recover function, and stops
+// the loop once the panics within the panic window reach the panic limit
+func (l *Loop) isolatedPanic(s *runState, r any) {
+	l.mu.Lock()
+	recoverFunc := l.recoverFunc
+	l.mu.Unlock()
+
+	if recoverFunc != nil {
+		recoverFunc(r)
+	}
+	l.logPanic(r)
+
+	if l.maxPanics == 0 || l.panicWindow == 0 {
+		return
+	}
+
+	// Concurrent render recovers its panics on the render goroutine
+	now := l.clock.Now()
+	l.mu.Lock()
+	s.panics = append(s.panics, now)
+	for now.Sub(s.panics[0]) > l.panicWindow {
+		s.panics = s.panics[1:]
+	}
+	limited := len(s.panics) >= l.maxPanics
+	if limited {
+		s.panics = nil
+	}
+	l.mu.Unlock()
+
+	if !limited {
+		return
+	}
+
+	l.stopWithError(ErrPanicLimit)
+	if l.onPanicLimit != nil {
+		info, _ := r.(PanicInfo)
+		l.onPanicLimit(info)
+	}
+}
+
+// pollInput calls the input function set, and reports whether the loop keeps running
+func pollInput(input InputFunc, inputBool InputFuncBool, isolated RecoverFunc) bool {
+	keepRunning := true
+	if inputBool != nil {
+		guard(PHASE_INPUT, isolated, func() {
+			keepRunning = inputBool()
+		})
+	} else {
+		guard(PHASE_INPUT, isolated, input)
+	}
+	return keepRunning
+}
+
+// guard calls fn, and if recoverFunc is set, recovers any panic in it
+// and passes it to recoverFunc along with the phase it happened in
+func guard(phase string, recoverFunc RecoverFunc, fn func()) {
+	if recoverFunc == nil {
+		fn()
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			recoverFunc(PanicInfo{Phase: phase, Value: r})
+		}
+	}()
+	fn()
+}
+
+// alpha returns the interpolation alpha for the given accumulator,
+// or 0 when the interpolation mode skips it
+func (l *Loop) alpha(accumulator, framePeriod time.Duration) float64 {
+	if l.fixedTimestep == 0 || l.interpolation == INTERPOLATION_NEVER {
+		return 0
+	}
+
+	if l.interpolation == INTERPOLATION_AUTO {
+		renderPeriod := framePeriod
+		if l.renderFps > 0 {
+			renderPeriod = l.renderPeriod
+		}
+		if renderPeriod >= l.fixedTimestep {
+			return 0
+		}
+	}
+	return float64(accumulator) / float64(l.fixedTimestep)
+}
+
+// frameBudget returns the time a frame can take before it overruns,
+// the shortest period of the update, render and input rates, or the
+// min frame time if longer
+func (l *Loop) frameBudget(framePeriod time.Duration) time.Duration {
+	budget := framePeriod
+	if l.renderFps > 0 {
+		budget = min(budget, l.renderPeriod)
+	}
+	if l.inputFps > 0 {
+		budget = min(budget, l.inputPeriod)
+	}
+	return max(budget, l.minFrameTime)
+}
+
+// setLastDelta records the delta time of the last update
+func (l *Loop) setLastDelta(delta, raw time.Duration) {
+	l.mu.Lock()
+	l.lastDelta = delta
+	l.lastRawDelta = raw
+	l.mu.Unlock()
+}
+
+// setLastSleep records the sleep time of the last frame
+func (l *Loop) setLastSleep(d time.Duration) {
+	l.mu.Lock()
+	l.lastSleep = d
+	l.mu.Unlock()
+}
+
+// stopping reports whether the loop was asked to stop during the current run
+func (l *Loop) stopping() bool {
+	select {
+	case <-l.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// maxSteps returns the most fixed updates the catch-up policy allows per frame,
+// zero when unlimited
+func (l *Loop) maxSteps() int {
+	switch l.catchUpPolicy {
+	case CATCH_UP:
+		return 0
+	case DROP_TO_LATEST:
+		return 1
+	default:
+		return l.maxUpdatesPerFrame
+	}
+}
+
+// nextDue reports whether a phase scheduled at *next is due at now,
+// in which case *next is moved forward by one period
+func nextDue(next *time.Time, now time.Time, period time.Duration) bool {
+	if now.Before(*next) {
+		return false
+	}
+
+	*next = next.Add(period)
+	if next.Before(now) {
+		// Too far behind, schedule from now instead of running a burst of catch-up frames
+		*next = now.Add(period)
+	}
+	return true
+}
+
+// scaleDelta multiplies d by the given time scale
+func scaleDelta(d time.Duration, scale float64) time.Duration {
+	if scale == 1 {
+		return d
+	}
+	return time.Duration(float64(d) * scale)
+}
+
+// unscaleDelta returns the real time behind the scaled delta d,
+// zero when the time scale freezes the simulation
+func unscaleDelta(d time.Duration, scale float64) time.Duration {
+	if scale == 1 {
+		return d
+	}
+
+	if scale == 0 {
+		return 0
+	}
+	return time.Duration(float64(d) / scale)
+}
+
+// clampDelta caps d to the max delta time, if one is set
+func (l *Loop) clampDelta(d time.Duration) time.Duration {
+	if l.maxDeltaTime > 0 {
+		return min(d, l.maxDeltaTime)
+	}
+	return d
+}
+
+// deltaWindow keeps the rolling average of the last n deltas added to it
+type deltaWindow struct {
+	deltas []time.Duration
+	next   int
+	count  int
+	sum    time.Duration
+}
+
+// add pushes d into a window of size n and returns the current average
+func (w *deltaWindow) add(d time.Duration, n int) time.Duration {
+	if len(w.deltas) != n {
+		w.deltas = make([]time.Duration, n)
+		w.reset()
+	}
+
+	if w.count == n {
+		w.sum -= w.deltas[w.next]
+	} else {
+		w.count++
+	}
+
+	w.deltas[w.next] = d
+	w.sum += d
+	w.next = (w.next + 1) % n
+	return w.sum / time.Duration(w.count)
+}
+
+func (w *deltaWindow) reset() {
+	w.next = 0
+	w.count = 0
+	w.sum = 0
+}
+
+// deltaEma is an exponential moving average of the variable deltas
+type deltaEma struct {
+	avg    float64
+	primed bool
+}
+
+// add folds d into the average with weight alpha and returns the new average.
+// The first nonzero delta after a reset starts the average, so the near zero
+// delta of the first frame doesn't drag it down.
+func (e *deltaEma) add(d time.Duration, alpha float64) time.Duration {
+	if !e.primed {
+		e.avg, e.primed = float64(d), d > 0
+	} else {
+		e.avg += alpha * (float64(d) - e.avg)
+	}
+	return time.Duration(e.avg)
+}
+
+func (e *deltaEma) reset() {
+	e.primed = false
 }
@@ -1,7 +1,19 @@
 package gyro_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"math"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -16,6 +28,10 @@ func TestStartWithNoUpdate(t *testing.T) {
 	if err.Error() != gyro.ERR_NO_UPDATE_FUNC {
 		t.Errorf("got %q, wanted %q", err, gyro.ERR_NO_UPDATE_FUNC)
 	}
+
+	if !errors.Is(err, gyro.ErrNoUpdateFunc) {
+		t.Errorf("got %v, wanted %v", err, gyro.ErrNoUpdateFunc)
+	}
 }
 
 func TestTargetFps(t *testing.T) {
@@ -87,3 +103,3779 @@ func TestFrameRate(t *testing.T) {
 	}
 
 }
+
+func TestFixedTimestep(t *testing.T) {
+	timestep := 10 * time.Millisecond
+	updates := 0
+
+	loop := gyro.NewLoop().
+		SetTargetFps(30).
+		SetFixedTimestep(timestep).
+		SetUpdateFunc(func(dt time.Duration) {
+			if dt != timestep {
+				t.Errorf("got delta time %v, wanted %v", dt, timestep)
+			}
+			updates++
+		})
+
+	if !loop.IsFixedTimestep() {
+		t.Fatalf("fixed timestep mode not active")
+	}
+
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		loop.Stop()
+	}()
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	// Roughly 50 updates over 500ms, allowing for the last frame's remainder
+	if updates < 40 || updates > 50 {
+		t.Fatalf("fixed update count out of range: got %v, wanted ~50", updates)
+	}
+}
+
+func TestRestartAfterStop(t *testing.T) {
+	frameCounter := 0
+
+	loop := gyro.NewLoop().
+		SetTargetFps(30).
+		SetUpdateFunc(func(dt time.Duration) {
+			frameCounter++
+		})
+
+	for run := 1; run <= 2; run++ {
+		before := frameCounter
+
+		go func() {
+			time.Sleep(200 * time.Millisecond)
+			loop.Stop()
+			loop.Stop()
+		}()
+
+		err := loop.Start()
+		if err != nil {
+			t.Fatalf("run %v: failed to start: %q", run, err.Error())
+		}
+
+		if loop.IsRunning() {
+			t.Fatalf("run %v: loop still running after stop", run)
+		}
+
+		if frameCounter <= before {
+			t.Fatalf("run %v: frames did not advance, got %v", run, frameCounter)
+		}
+	}
+}
+
+func TestRestartWhileStopping(t *testing.T) {
+	draining, release := make(chan struct{}), make(chan struct{})
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(60).
+		SetUpdateFunc(func(dt time.Duration) {
+			if loop.IsStopping() {
+				// Block the drain frame until the restart was attempted
+				close(draining)
+				<-release
+			}
+		})
+
+	done, err := loop.StartAsync()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	loop.StopAndDrain()
+	<-draining
+
+	// The previous run still owns the loop until it exits
+	if _, err := loop.StartAsync(); !errors.Is(err, gyro.ErrRunning) {
+		t.Fatalf("got %v restarting during the drain frame, wanted ErrRunning", err)
+	}
+	if err := loop.RunFrames(1); !errors.Is(err, gyro.ErrRunning) {
+		t.Fatalf("got %v running frames during the drain frame, wanted ErrRunning", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("got %v from the drained run", err)
+	}
+
+	// Once it exited, the loop starts again
+	loop.SetUpdateFunc(func(dt time.Duration) {})
+	if err := loop.RunFrames(2); err != nil || loop.GetFrameCount() != 2 {
+		t.Fatalf("got %v and %v frames restarting after the drain, wanted 2 frames", err, loop.GetFrameCount())
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	var inputs, updates atomic.Int32
+	var maxDelta time.Duration
+
+	loop := gyro.NewLoop().
+		SetTargetFps(30).
+		SetInputWhilePaused(true)
+
+	loop.SetInputFunc(func() {
+		inputs.Add(1)
+	}).SetUpdateFunc(func(dt time.Duration) {
+		updates.Add(1)
+		maxDelta = max(maxDelta, dt)
+	})
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		loop.Pause()
+		time.Sleep(50 * time.Millisecond)
+		pausedUpdates, pausedInputs := updates.Load(), inputs.Load()
+		time.Sleep(300 * time.Millisecond)
+
+		if !loop.IsPaused() {
+			t.Errorf("loop not paused")
+		}
+		if updates.Load() != pausedUpdates {
+			t.Errorf("update ran while paused: got %v updates, wanted %v", updates.Load(), pausedUpdates)
+		}
+		if inputs.Load() <= pausedInputs {
+			t.Errorf("input did not run while paused")
+		}
+
+		loop.Resume()
+		time.Sleep(100 * time.Millisecond)
+		loop.Stop()
+	}()
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if maxDelta >= 300*time.Millisecond {
+		t.Fatalf("delta time included paused duration: got %v", maxDelta)
+	}
+}
+
+func TestStartContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	loop := gyro.NewLoop().
+		SetUpdateFunc(func(dt time.Duration) {})
+
+	err := loop.StartContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, wanted %v", err, context.DeadlineExceeded)
+	}
+
+	if loop.IsRunning() {
+		t.Fatalf("loop still running after context cancellation")
+	}
+}
+
+func TestConcurrentGetters(t *testing.T) {
+	loop := gyro.NewLoop().
+		SetTargetFps(120).
+		SetUpdateFunc(func(dt time.Duration) {})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		deadline := time.Now().Add(1200 * time.Millisecond)
+		for time.Now().Before(deadline) {
+			loop.GetCurrentFps()
+			loop.IsRunning()
+			loop.IsPaused()
+		}
+		loop.Stop()
+	}()
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+	<-done
+}
+
+func TestMaxDeltaTime(t *testing.T) {
+	maxDelta := 20 * time.Millisecond
+
+	loop := gyro.NewLoop().
+		SetTargetFps(30).
+		SetMaxDeltaTime(maxDelta).
+		SetUpdateFunc(func(dt time.Duration) {
+			if dt > maxDelta {
+				t.Errorf("delta time exceeds cap: got %v, wanted at most %v", dt, maxDelta)
+			}
+		})
+
+	// A slow input stalls every frame well past the cap before update runs
+	loop.SetInputFunc(func() {
+		time.Sleep(50 * time.Millisecond)
+	})
+
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		loop.Stop()
+	}()
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	// In fixed timestep mode the cap limits the time the accumulator takes in
+	clock := gyro.NewFakeClock()
+	steps, perFrame := 0, []int{}
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(clock).
+		SetFixedTimestep(10 * time.Millisecond).
+		SetCatchUpPolicy(gyro.CATCH_UP).
+		SetMaxDeltaTime(300 * time.Millisecond).
+		SetUpdateFunc(func(dt time.Duration) {
+			steps++
+		}).
+		SetBeforeFrame(func(frame gyro.Frame) {
+			// Stall a frame for 1s, 100 fixed steps without the cap
+			if frame.Number == 2 {
+				clock.Advance(time.Second)
+			}
+		}).
+		SetStatsFunc(func(stats gyro.FrameStats) {
+			perFrame = append(perFrame, steps)
+			steps = 0
+		})
+
+	if err := loop.RunFrames(5); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	// The frame after the stall only runs the 30 steps of the 300ms cap
+	if want := []int{0, 10, 10, 30, 10}; !slices.Equal(perFrame, want) {
+		t.Fatalf("got %v fixed updates per frame, wanted %v", perFrame, want)
+	}
+}
+
+func TestTimeScale(t *testing.T) {
+	var renders atomic.Int32
+	var maxDelta time.Duration
+
+	loop := gyro.NewLoop().
+		SetTargetFps(30).
+		SetTimeScale(0).
+		SetUpdateFunc(func(dt time.Duration) {
+			maxDelta = max(maxDelta, dt)
+		})
+
+	loop.SetRenderFunc(func() {
+		renders.Add(1)
+	})
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		loop.Stop()
+	}()
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if maxDelta != 0 {
+		t.Fatalf("got delta time %v with a time scale of 0, wanted 0", maxDelta)
+	}
+
+	if renders.Load() == 0 {
+		t.Fatalf("render not called with a time scale of 0")
+	}
+}
+
+func TestStatsFunc(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	var frames []gyro.FrameStats
+
+	loop := gyro.NewLoop().
+		SetTargetFps(30).
+		SetClock(clock).
+		SetUpdateFunc(func(dt time.Duration) {
+			clock.Advance(5 * time.Millisecond)
+		})
+
+	loop.SetStatsFunc(func(stats gyro.FrameStats) {
+		frames = append(frames, stats)
+	})
+
+	err := loop.RunFrames(6)
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if len(frames) != 6 {
+		t.Fatalf("got %v stats calls, wanted 6", len(frames))
+	}
+
+	for i, stats := range frames {
+		if stats.Update != 5*time.Millisecond {
+			t.Errorf("frame %v: got update duration %v, wanted 5ms", i, stats.Update)
+		}
+		if stats.Input != 0 || stats.Render != 0 {
+			t.Errorf("frame %v: got non-zero duration for nil phases: input %v, render %v", i, stats.Input, stats.Render)
+		}
+		if stats.Frame < stats.Update {
+			t.Errorf("frame %v: frame duration %v shorter than update duration %v", i, stats.Frame, stats.Update)
+		}
+
+		// The last frame stops the loop, and a stopping frame skips its sleep
+		last := i == len(frames)-1
+		if !last && stats.Sleep != loop.GetTargetPeriod()-5*time.Millisecond {
+			t.Errorf("frame %v: got sleep time %v for a frame within budget", i, stats.Sleep)
+		}
+		if last && stats.Sleep != 0 {
+			t.Errorf("got sleep time %v for the stopping frame, wanted none", stats.Sleep)
+		}
+	}
+}
+
+func TestRenderFps(t *testing.T) {
+	tolerance := 5
+	updateFps, renderFps := 20, 60
+
+	loop := gyro.NewLoop().
+		SetTargetFps(updateFps).
+		SetRenderFps(renderFps).
+		SetUpdateFunc(func(dt time.Duration) {})
+
+	loop.SetRenderFunc(func() {})
+
+	go func() {
+		time.Sleep(1200 * time.Millisecond)
+		loop.Stop()
+	}()
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if diff := int(math.Abs(float64(loop.GetCurrentUpdateFps() - updateFps))); diff > tolerance {
+		t.Fatalf("update fps mismatch: got %v, wanted %v", loop.GetCurrentUpdateFps(), updateFps)
+	}
+
+	if diff := int(math.Abs(float64(loop.GetCurrentRenderFps() - renderFps))); diff > tolerance {
+		t.Fatalf("render fps mismatch: got %v, wanted %v", loop.GetCurrentRenderFps(), renderFps)
+	}
+}
+
+func TestRenderFuncAlpha(t *testing.T) {
+	renders := 0
+
+	loop := gyro.NewLoop().
+		SetTargetFps(60).
+		SetFixedTimestep(7 * time.Millisecond).
+		SetUpdateFunc(func(dt time.Duration) {})
+
+	loop.SetRenderFuncAlpha(func(alpha float64) {
+		renders++
+		if alpha < 0 || alpha >= 1 {
+			t.Errorf("alpha out of bounds: got %v, wanted [0, 1)", alpha)
+		}
+	})
+
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		loop.Stop()
+	}()
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if renders == 0 {
+		t.Fatalf("render function never called")
+	}
+}
+
+func TestLifecycleHooks(t *testing.T) {
+	var events []string
+
+	loop := gyro.NewLoop().
+		SetTargetFps(30).
+		SetOnStart(func() {
+			events = append(events, "start")
+		}).
+		SetOnStop(func() {
+			events = append(events, "stop")
+		}).
+		SetRecoverFunc(func(r any) {
+			events = append(events, "recover")
+		}).
+		SetUpdateFunc(func(dt time.Duration) {
+			if len(events) == 1 {
+				events = append(events, "update")
+				panic("update failed")
+			}
+		})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	want := []string{"start", "update", "stop", "recover"}
+	if fmt.Sprint(events) != fmt.Sprint(want) {
+		t.Fatalf("got events %v, wanted %v", events, want)
+	}
+}
+
+func TestStopStrict(t *testing.T) {
+	loop := gyro.NewLoop().
+		SetUpdateFunc(func(dt time.Duration) {})
+
+	if err := loop.Stop(); err != nil {
+		t.Fatalf("got %v from lenient stop, wanted nil", err)
+	}
+
+	if err := loop.StopStrict(); !errors.Is(err, gyro.ErrNotRunning) {
+		t.Fatalf("got %v, wanted %v", err, gyro.ErrNotRunning)
+	}
+}
+
+func TestOnFrameOverrun(t *testing.T) {
+	overruns := 0
+
+	loop := gyro.NewLoop().
+		SetTargetFps(50).
+		SetUpdateFunc(func(dt time.Duration) {
+			time.Sleep(30 * time.Millisecond)
+		}).
+		SetOnFrameOverrun(func(over time.Duration) {
+			overruns++
+			if over < 10*time.Millisecond {
+				t.Errorf("overrun too short: got %v, wanted at least 10ms", over)
+			}
+		})
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		loop.Stop()
+	}()
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if overruns == 0 {
+		t.Fatalf("overrun function never called")
+	}
+}
+
+func TestFramePeriodPrecision(t *testing.T) {
+	// Median period can be 0.3ms above or below the target,
+	// the median keeps scheduler hiccups from skewing the result
+	tolerance := 300 * time.Microsecond
+	wantPeriod := time.Second / 60
+	var periods []time.Duration
+	var last time.Time
+
+	loop := gyro.NewLoop().
+		SetTargetFps(60).
+		SetUpdateFunc(func(dt time.Duration) {
+			now := time.Now()
+			if !last.IsZero() {
+				periods = append(periods, now.Sub(last))
+			}
+			last = now
+		})
+
+	go func() {
+		time.Sleep(1 * time.Second)
+		loop.Stop()
+	}()
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	slices.Sort(periods)
+	period := periods[len(periods)/2]
+	if diff := (period - wantPeriod).Abs(); diff > tolerance {
+		t.Fatalf("frame period off target: got %v, wanted %v (max diff %v)", period, wantPeriod, tolerance)
+	}
+}
+
+func TestTargetFpsFloat(t *testing.T) {
+	fps := 59.94
+	loop := gyro.NewLoop().
+		SetTargetFpsFloat(fps)
+
+	if loop.GetTargetFps() != 60 {
+		t.Fatalf("got rounded target fps %v, wanted 60", loop.GetTargetFps())
+	}
+
+	wantPeriod := time.Duration(float64(time.Second) / fps)
+	if loop.GetTargetPeriod() != wantPeriod {
+		t.Fatalf("got target period %v, wanted %v", loop.GetTargetPeriod(), wantPeriod)
+	}
+
+	loop.SetTargetFps(-5)
+	if loop.GetTargetFps() != 1 || loop.GetTargetPeriod() != time.Second {
+		t.Fatalf("negative fps not clamped: got %v fps with period %v", loop.GetTargetFps(), loop.GetTargetPeriod())
+	}
+}
+
+func TestUncapped(t *testing.T) {
+	targetFps := 10
+
+	loop := gyro.NewLoop().
+		SetTargetFps(targetFps).
+		SetUncapped(true).
+		SetUpdateFunc(func(dt time.Duration) {})
+
+	go func() {
+		time.Sleep(1200 * time.Millisecond)
+		loop.Stop()
+	}()
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if loop.GetCurrentFps() <= targetFps*10 {
+		t.Fatalf("uncapped loop didn't run past the target fps: got %v", loop.GetCurrentFps())
+	}
+}
+
+func TestDeltaSmoothing(t *testing.T) {
+	frames := 0
+	var minDelta, maxDelta time.Duration
+
+	loop := gyro.NewLoop().
+		SetTargetFps(50).
+		SetDeltaSmoothing(10)
+
+	// Every other frame stalls, so raw deltas alternate between short and long
+	loop.SetInputFunc(func() {
+		if frames%2 == 0 {
+			time.Sleep(40 * time.Millisecond)
+		}
+	}).SetUpdateFunc(func(dt time.Duration) {
+		frames++
+		if frames > 10 {
+			if minDelta == 0 || dt < minDelta {
+				minDelta = dt
+			}
+			maxDelta = max(maxDelta, dt)
+		}
+	})
+
+	go func() {
+		time.Sleep(800 * time.Millisecond)
+		loop.Stop()
+	}()
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if diff := maxDelta - minDelta; diff > 10*time.Millisecond {
+		t.Fatalf("smoothed delta times vary too much: got %v to %v", minDelta, maxDelta)
+	}
+}
+
+func TestStopAndDrain(t *testing.T) {
+	updates, renders := 0, 0
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(60).
+		SetUpdateFunc(func(dt time.Duration) {
+			updates++
+		})
+
+	loop.SetRenderFunc(func() {
+		renders++
+		if renders == 3 {
+			loop.StopAndDrain()
+		}
+	})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if updates != 4 || renders != 4 {
+		t.Fatalf("got %v updates and %v renders, wanted exactly one drain frame after 3", updates, renders)
+	}
+
+	// A paused loop still runs the whole drain frame
+	updates, renders = 0, 0
+	var frames uint64
+	loop.SetClock(gyro.NewFakeClock()).
+		SetUpdateFunc(func(dt time.Duration) {
+			updates++
+			loop.Pause()
+		}).
+		SetRenderFunc(func() {
+			renders++
+		}).
+		SetAfterFrame(func(frame gyro.Frame) {
+			if frames++; frames == 3 {
+				loop.StopAndDrain()
+			}
+		})
+
+	if err := loop.Start(); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	// The first frame pauses, the next two are skipped, then the drain frame runs
+	if updates != 2 || renders != 2 || frames != 4 {
+		t.Fatalf("got %v updates and %v renders over %v frames, wanted 2 each over 4", updates, renders, frames)
+	}
+}
+
+func TestSwapRenderFunc(t *testing.T) {
+	var first, second atomic.Int32
+
+	loop := gyro.NewLoop().
+		SetTargetFps(120).
+		SetUpdateFunc(func(dt time.Duration) {})
+
+	loop.SetRenderFunc(func() {
+		first.Add(1)
+	})
+
+	go func() {
+		for i := 0; i < 20; i++ {
+			time.Sleep(10 * time.Millisecond)
+			if i%2 == 0 {
+				loop.SetRenderFunc(func() {
+					second.Add(1)
+				})
+			} else {
+				loop.SetRenderFunc(func() {
+					first.Add(1)
+				})
+			}
+		}
+		loop.Stop()
+	}()
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if first.Load() == 0 || second.Load() == 0 {
+		t.Fatalf("render swap had no effect: got %v and %v renders", first.Load(), second.Load())
+	}
+}
+
+func TestWaitUntilRunning(t *testing.T) {
+	loop := gyro.NewLoop().
+		SetUpdateFunc(func(dt time.Duration) {})
+
+	if loop.WaitUntilRunning(10 * time.Millisecond) {
+		t.Fatalf("loop reported running before start")
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- loop.Start()
+	}()
+
+	if !loop.WaitUntilRunning(time.Second) {
+		t.Fatalf("loop didn't start within timeout")
+	}
+
+	if !loop.IsRunning() {
+		t.Fatalf("loop not running after start signal")
+	}
+
+	loop.Stop()
+	if err := <-done; err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+}
+
+func TestIsolatePanics(t *testing.T) {
+	updates := 0
+	var panics []gyro.PanicInfo
+
+	loop := gyro.NewLoop().
+		SetTargetFps(60).
+		SetIsolatePanics(true).
+		SetRecoverFunc(func(r any) {
+			panics = append(panics, r.(gyro.PanicInfo))
+		})
+
+	loop.SetUpdateFunc(func(dt time.Duration) {
+		updates++
+		if updates <= 2 {
+			panic("update failed")
+		}
+		if updates == 5 {
+			loop.Stop()
+		}
+	})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if updates != 5 {
+		t.Fatalf("loop didn't continue after panics: got %v updates, wanted 5", updates)
+	}
+
+	if len(panics) != 2 || panics[0].Phase != gyro.PHASE_UPDATE || panics[0].Value != "update failed" {
+		t.Fatalf("got panics %v, wanted 2 update panics", panics)
+	}
+}
+
+func TestSystems(t *testing.T) {
+	var calls []string
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		AddSystem("physics", func(dt time.Duration) {
+			calls = append(calls, "physics")
+		}).
+		AddSystem("ai", func(dt time.Duration) {
+			calls = append(calls, "ai")
+		}).
+		SetUpdateFunc(func(dt time.Duration) {
+			calls = append(calls, "default")
+			if len(calls) == 3 {
+				loop.RemoveSystem("ai")
+			} else {
+				loop.Stop()
+			}
+		})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	want := []string{"physics", "ai", "default", "physics", "default"}
+	if fmt.Sprint(calls) != fmt.Sprint(want) {
+		t.Fatalf("got system calls %v, wanted %v", calls, want)
+	}
+}
+
+func TestStepCancelContext(t *testing.T) {
+	busy, release := make(chan struct{}), make(chan struct{})
+	updates := 0
+
+	loop := gyro.NewLoop().
+		SetStepMode(true).
+		SetUpdateFunc(func(dt time.Duration) {
+			updates++
+			if updates == 1 {
+				close(busy)
+				<-release
+			}
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	result := make(chan error, 1)
+	go func() {
+		result <- loop.StartContext(ctx)
+	}()
+	if !loop.WaitUntilRunning(time.Second) {
+		t.Fatalf("loop did not start")
+	}
+
+	go loop.Step(0)
+	<-busy
+
+	// Cancel while a second step waits for the first one to finish
+	cancel()
+	pending := make(chan error, 1)
+	go func() {
+		pending <- loop.Step(0)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	select {
+	case err := <-pending:
+		if !errors.Is(err, gyro.ErrNotRunning) {
+			t.Fatalf("got %v from the pending step, wanted ErrNotRunning", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("pending step still blocked after the context was cancelled")
+	}
+
+	if err := <-result; !errors.Is(err, context.Canceled) || updates != 1 {
+		t.Fatalf("got %v after %v updates, wanted context.Canceled after 1", err, updates)
+	}
+}
+
+func TestStepMode(t *testing.T) {
+	var deltas []time.Duration
+
+	loop := gyro.NewLoop().
+		SetTargetFps(60).
+		SetStepMode(true).
+		SetUpdateFunc(func(dt time.Duration) {
+			deltas = append(deltas, dt)
+		})
+
+	done := make(chan error)
+	go func() {
+		done <- loop.Start()
+	}()
+	loop.WaitUntilRunning(time.Second)
+
+	for _, dt := range []time.Duration{5 * time.Millisecond, 0, 20 * time.Millisecond} {
+		if err := loop.Step(dt); err != nil {
+			t.Fatalf("failed to step: %q", err.Error())
+		}
+	}
+
+	// Automatic frames must not run between steps
+	time.Sleep(50 * time.Millisecond)
+	want := []time.Duration{5 * time.Millisecond, time.Second / 60, 20 * time.Millisecond}
+	if fmt.Sprint(deltas) != fmt.Sprint(want) {
+		t.Fatalf("got step deltas %v, wanted %v", deltas, want)
+	}
+
+	loop.SetStepMode(false)
+	if err := loop.Step(0); !errors.Is(err, gyro.ErrNotStepMode) {
+		t.Fatalf("got %v, wanted %v", err, gyro.ErrNotStepMode)
+	}
+
+	loop.Stop()
+	if err := <-done; err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+}
+
+func TestFakeClock(t *testing.T) {
+	var deltas []time.Duration
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(gyro.NewFakeClock()).
+		SetUpdateFunc(func(dt time.Duration) {
+			deltas = append(deltas, dt)
+			if len(deltas) == 25 {
+				loop.Stop()
+			}
+		})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	// Callbacks take no time on the fake clock, so every delta is exactly one sleep
+	for i, dt := range deltas[1:] {
+		if dt != 100*time.Millisecond {
+			t.Fatalf("frame %v: got delta time %v, wanted 100ms", i+1, dt)
+		}
+	}
+
+	if loop.GetCurrentFps() != 10 {
+		t.Fatalf("got current fps %v, wanted exactly 10", loop.GetCurrentFps())
+	}
+}
+
+func TestFrameCountAndElapsed(t *testing.T) {
+	var lastCount uint64
+	var lastElapsed time.Duration
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(gyro.NewFakeClock())
+
+	loop.SetUpdateFunc(func(dt time.Duration) {
+		count, elapsed := loop.GetFrameCount(), loop.GetElapsed()
+		if count > 0 && (count <= lastCount || elapsed < lastElapsed) {
+			t.Errorf("frame count or elapsed went backwards: got %v after %v, %v after %v", count, lastCount, elapsed, lastElapsed)
+		}
+
+		lastCount, lastElapsed = count, elapsed
+		if count == 20 {
+			loop.Stop()
+		}
+	})
+
+	for run := 1; run <= 2; run++ {
+		lastCount, lastElapsed = 0, 0
+		err := loop.Start()
+		if err != nil {
+			t.Fatalf("run %v: failed to start: %q", run, err.Error())
+		}
+
+		if loop.GetFrameCount() != 21 || loop.GetElapsed() != 2*time.Second {
+			t.Fatalf("run %v: got %v frames in %v, wanted 21 in 2s", run, loop.GetFrameCount(), loop.GetElapsed())
+		}
+	}
+}
+
+func TestMaxRenderSkip(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	frames, renders, maxSkipped, skipped := 0, 0, 0, 0
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(clock).
+		SetFixedTimestep(20 * time.Millisecond).
+		SetMaxRenderSkip(2)
+
+	// Every frame takes 5 updates worth of time, so updates are always behind
+	loop.SetInputFunc(func() {
+		clock.Advance(100 * time.Millisecond)
+		frames++
+		skipped++
+		if frames == 30 {
+			loop.Stop()
+		}
+	}).SetUpdateFunc(func(dt time.Duration) {})
+
+	loop.SetRenderFunc(func() {
+		renders++
+		maxSkipped = max(maxSkipped, skipped-1)
+		skipped = 0
+	})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if maxSkipped > 2 {
+		t.Fatalf("got %v consecutive frames without render, wanted at most 2", maxSkipped)
+	}
+
+	if dropped := loop.GetDroppedFrames(); dropped == 0 || int(dropped)+renders != frames {
+		t.Fatalf("got %v dropped frames and %v renders, wanted them to add up to %v", dropped, renders, frames)
+	}
+}
+
+func TestOnFpsSample(t *testing.T) {
+	var samples []int
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(20).
+		SetClock(gyro.NewFakeClock()).
+		SetUpdateFunc(func(dt time.Duration) {}).
+		SetOnFpsSample(func(fps int) {
+			samples = append(samples, fps)
+			if fps != loop.GetCurrentFps() {
+				t.Errorf("sampled fps %v doesn't match current fps %v", fps, loop.GetCurrentFps())
+			}
+			if len(samples) == 3 {
+				loop.Stop()
+			}
+		})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if samples[len(samples)-1] != 20 {
+		t.Fatalf("got fps samples %v, wanted 20", samples)
+	}
+}
+
+func TestFpsSampleWindow(t *testing.T) {
+	samples := 0
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(40).
+		SetClock(gyro.NewFakeClock()).
+		SetFpsSampleWindow(250 * time.Millisecond).
+		SetUpdateFunc(func(dt time.Duration) {}).
+		SetOnFpsSample(func(fps int) {
+			samples++
+			if fps != 40 {
+				t.Errorf("sample %v: got fps %v, wanted 40", samples, fps)
+			}
+			if samples == 4 {
+				loop.Stop()
+			}
+		})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	// Four 250ms windows of 10 frames each, plus the frame that took the last sample
+	if loop.GetFrameCount() != 41 {
+		t.Fatalf("got %v frames for 4 samples, wanted 41", loop.GetFrameCount())
+	}
+}
+
+func TestValidate(t *testing.T) {
+	loop, err := gyro.NewLoop().
+		SetUpdateFunc(func(dt time.Duration) {}).
+		Build()
+	if err != nil || loop == nil {
+		t.Fatalf("got %v for a valid configuration, wanted nil", err)
+	}
+
+	_, err = gyro.NewLoop().
+		SetUncapped(true).
+		SetFixedTimestep(10 * time.Millisecond).
+		Build()
+	if !errors.Is(err, gyro.ErrNoUpdateFunc) || !errors.Is(err, gyro.ErrFixedUncapped) {
+		t.Fatalf("got %v, wanted %v and %v", err, gyro.ErrNoUpdateFunc, gyro.ErrFixedUncapped)
+	}
+
+	err = gyro.NewLoop().
+		SetUpdateFunc(func(dt time.Duration) {}).
+		SetMaxRenderSkip(2).
+		Start()
+	if !errors.Is(err, gyro.ErrRenderSkipNotFixed) {
+		t.Fatalf("got %v from Start, wanted %v", err, gyro.ErrRenderSkipNotFixed)
+	}
+}
+
+func TestStartOnCurrentThread(t *testing.T) {
+	updates := 0
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(60).
+		SetLockOSThread(true).
+		SetUpdateFunc(func(dt time.Duration) {
+			updates++
+			if updates == 3 {
+				loop.Stop()
+			}
+		})
+
+	err := loop.StartOnCurrentThread()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if updates != 3 {
+		t.Fatalf("got %v updates, wanted 3", updates)
+	}
+}
+
+func TestInputFps(t *testing.T) {
+	inputs, updates := 0, 0
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(20).
+		SetInputFps(100).
+		SetClock(gyro.NewFakeClock())
+
+	loop.SetInputFunc(func() {
+		inputs++
+	}).SetUpdateFunc(func(dt time.Duration) {
+		updates++
+		if updates == 20 {
+			loop.Stop()
+		}
+	})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	// Input is polled 5 times per update, the last update ends the run right away
+	if want := 19*5 + 1; inputs != want {
+		t.Fatalf("got %v input polls for %v updates, wanted %v", inputs, updates, want)
+	}
+}
+
+func TestOnSustainedOverrun(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	frames := 0
+	var overruns []int
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(20).
+		SetClock(clock).
+		SetOnSustainedOverrun(0.8, func(fps int) {
+			overruns = append(overruns, fps)
+		})
+
+	// Slow frames run at 10fps for 3s, then fast ones at 20fps for 2s, then slow again
+	loop.SetUpdateFunc(func(dt time.Duration) {
+		frames++
+		if frames <= 30 || frames > 70 {
+			clock.Advance(100 * time.Millisecond)
+		}
+		if frames == 100 {
+			loop.Stop()
+		}
+	})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if fmt.Sprint(overruns) != fmt.Sprint([]int{10, 10}) {
+		t.Fatalf("got sustained overruns %v, wanted one per slow stretch", overruns)
+	}
+}
+
+func TestReset(t *testing.T) {
+	updates := 0
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(gyro.NewFakeClock()).
+		SetUpdateFunc(func(dt time.Duration) {
+			updates++
+			if updates%15 == 0 {
+				loop.Stop()
+			}
+		})
+
+	if err := loop.Start(); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if err := loop.Reset(true); err != nil {
+		t.Fatalf("failed to reset: %q", err.Error())
+	}
+
+	if loop.GetTargetFps() != gyro.DEFAULT_FPS || loop.GetFrameCount() != 0 || loop.GetCurrentFps() != 0 {
+		t.Fatalf("reset kept state: got %v target fps, %v frames, %v current fps", loop.GetTargetFps(), loop.GetFrameCount(), loop.GetCurrentFps())
+	}
+
+	// The kept update function still runs and stops the loop
+	if err := loop.Start(); err != nil || updates != 30 {
+		t.Fatalf("got %v and %v updates after reset, wanted nil and 30", err, updates)
+	}
+
+	loop.Reset(false)
+	if err := loop.Start(); !errors.Is(err, gyro.ErrNoUpdateFunc) {
+		t.Fatalf("got %v after clearing callbacks, wanted %v", err, gyro.ErrNoUpdateFunc)
+	}
+
+	// A stopped loop can only be reset once its last frame finished
+	release := make(chan struct{})
+	loop.SetUpdateFunc(func(dt time.Duration) {
+		if loop.IsStopping() {
+			<-release
+		}
+	})
+	if _, err := loop.StartAsync(); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+	loop.Stop()
+	if err := loop.Reset(true); !errors.Is(err, gyro.ErrRunning) {
+		t.Fatalf("got %v resetting a stopping loop, wanted ErrRunning", err)
+	}
+
+	close(release)
+	<-loop.Done()
+	if err := loop.Reset(true); err != nil {
+		t.Fatalf("got %v resetting after Done, wanted nil", err)
+	}
+}
+
+func TestDebugTrace(t *testing.T) {
+	var trace bytes.Buffer
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(gyro.NewFakeClock()).
+		SetDebug(true).
+		SetDebugWriter(&trace).
+		SetUpdateFunc(func(dt time.Duration) {
+			if loop.GetFrameCount() == 2 {
+				loop.Stop()
+			}
+		})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	lines := strings.Split(strings.TrimSpace(trace.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "gyro: frame=2 delta=100ms") {
+		t.Fatalf("unexpected debug trace:\n%s", trace.String())
+	}
+}
+
+func TestLateUpdate(t *testing.T) {
+	var calls []string
+	var panics []gyro.PanicInfo
+	var lateDelta time.Duration
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(gyro.NewFakeClock()).
+		SetIsolatePanics(true).
+		SetRecoverFunc(func(r any) {
+			panics = append(panics, r.(gyro.PanicInfo))
+		}).
+		SetUpdateFunc(func(dt time.Duration) {
+			calls = append(calls, "update")
+		}).
+		SetLateUpdateFunc(func(dt time.Duration) {
+			calls = append(calls, "late")
+			lateDelta = dt
+			if loop.GetFrameCount() == 0 {
+				panic("late update failed")
+			}
+			if loop.GetFrameCount() == 1 {
+				loop.Stop()
+			}
+		}).
+		SetRenderFunc(func() {
+			calls = append(calls, "render")
+		})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	want := []string{"update", "late", "render", "update", "late", "render"}
+	if !slices.Equal(calls, want) {
+		t.Fatalf("got calls %v, wanted %v", calls, want)
+	}
+
+	if lateDelta != 100*time.Millisecond {
+		t.Fatalf("late update got delta %v, wanted 100ms", lateDelta)
+	}
+
+	if len(panics) != 1 || panics[0].Phase != gyro.PHASE_LATE_UPDATE {
+		t.Fatalf("got panics %v, wanted 1 late update panic", panics)
+	}
+}
+
+func TestDeltaSource(t *testing.T) {
+	recorded := []time.Duration{5 * time.Millisecond, 40 * time.Millisecond, 15 * time.Millisecond}
+	var deltas []time.Duration
+	var loop *gyro.Loop
+
+	next := 0
+	loop = gyro.NewLoop().
+		SetTargetFps(60).
+		SetClock(gyro.NewFakeClock()).
+		SetTimeScale(2).
+		SetMaxDeltaTime(10 * time.Millisecond).
+		SetDeltaSource(func() time.Duration {
+			delta := recorded[next]
+			next++
+			return delta
+		}).
+		SetUpdateFunc(func(dt time.Duration) {
+			deltas = append(deltas, dt)
+			if len(deltas) == len(recorded) {
+				loop.Stop()
+			}
+		})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	// Recorded deltas bypass the time scale and the max delta time
+	if !slices.Equal(deltas, recorded) {
+		t.Fatalf("got deltas %v, wanted %v", deltas, recorded)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	var running gyro.LoopMetrics
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(clock).
+		SetUpdateFunc(func(dt time.Duration) {
+			clock.Advance(30 * time.Millisecond)
+			if loop.GetFrameCount() == 4 {
+				running = loop.Snapshot()
+				loop.Stop()
+			}
+		})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if !running.IsRunning || running.FrameCount != 4 || running.LastFrameTime != 30*time.Millisecond || running.TargetFps != 10 {
+		t.Fatalf("unexpected snapshot while running: %+v", running)
+	}
+
+	stopped := loop.Snapshot()
+	if stopped.IsRunning || stopped.FrameCount != 5 || stopped.Elapsed != 430*time.Millisecond {
+		t.Fatalf("unexpected snapshot after stop: %+v", stopped)
+	}
+}
+
+func TestStartAsync(t *testing.T) {
+	_, err := gyro.NewLoop().StartAsync()
+	if !errors.Is(err, gyro.ErrNoUpdateFunc) {
+		t.Fatalf("got %v, wanted %v", err, gyro.ErrNoUpdateFunc)
+	}
+
+	loop := gyro.NewLoop().
+		SetTargetFps(60).
+		SetUpdateFunc(func(dt time.Duration) {})
+
+	done, err := loop.StartAsync()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if !loop.IsRunning() {
+		t.Fatalf("loop not running after StartAsync returned")
+	}
+
+	if _, err := loop.StartAsync(); !errors.Is(err, gyro.ErrRunning) {
+		t.Fatalf("got %v, wanted %v", err, gyro.ErrRunning)
+	}
+
+	loop.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("loop exited with %q", err.Error())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("done did not receive after stop")
+	}
+}
+
+func TestCatchUpPolicy(t *testing.T) {
+	policies := map[gyro.CatchUpPolicy]int{
+		gyro.CATCH_UP_CLAMPED: 15,
+		gyro.CATCH_UP:         30,
+		gyro.DROP_TO_LATEST:   3,
+	}
+
+	for policy, want := range policies {
+		updates := 0
+		var loop *gyro.Loop
+
+		// Every 100ms frame owes 10 updates of 10ms
+		loop = gyro.NewLoop().
+			SetTargetFps(10).
+			SetClock(gyro.NewFakeClock()).
+			SetFixedTimestep(10 * time.Millisecond).
+			SetCatchUpPolicy(policy).
+			SetUpdateFunc(func(dt time.Duration) {
+				updates++
+			}).
+			SetRenderFunc(func() {
+				if loop.GetFrameCount() == 3 {
+					loop.Stop()
+				}
+			})
+
+		err := loop.Start()
+		if err != nil {
+			t.Fatalf("policy %v: failed to start: %q", policy, err.Error())
+		}
+
+		if updates != want {
+			t.Fatalf("policy %v: got %v updates, wanted %v", policy, updates, want)
+		}
+	}
+}
+
+func TestFrameCtx(t *testing.T) {
+	var updates, renders []gyro.Frame
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(gyro.NewFakeClock()).
+		SetFixedTimestep(40 * time.Millisecond).
+		SetUpdateFuncCtx(func(frame gyro.Frame) {
+			updates = append(updates, frame)
+		}).
+		SetRenderFuncCtx(func(frame gyro.Frame) {
+			renders = append(renders, frame)
+			if frame.Number == 2 {
+				loop.Stop()
+			}
+		})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if len(renders) != 3 || len(updates) != 5 {
+		t.Fatalf("got %v renders and %v updates, wanted 3 and 5", len(renders), len(updates))
+	}
+
+	// Frame 1 runs two 40ms updates and carries 20ms over, frame 2 runs three
+	if renders[1].Delta != 80*time.Millisecond || renders[1].Alpha != 0.5 {
+		t.Fatalf("unexpected render frame: %+v", renders[1])
+	}
+
+	if renders[2].Number != 2 || renders[2].Elapsed != 200*time.Millisecond || renders[2].Delta != 120*time.Millisecond {
+		t.Fatalf("unexpected render frame: %+v", renders[2])
+	}
+
+	if updates[0].Number != 1 || updates[0].Delta != 40*time.Millisecond || updates[0].Alpha != 0 {
+		t.Fatalf("unexpected update frame: %+v", updates[0])
+	}
+}
+
+func TestStopFromCallback(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	updates, renders := 0, 0
+	var loop *gyro.Loop
+
+	// Every 100ms frame owes 10 updates of 10ms
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(clock).
+		SetFixedTimestep(10 * time.Millisecond).
+		SetCatchUpPolicy(gyro.CATCH_UP).
+		SetUpdateFunc(func(dt time.Duration) {
+			updates++
+			if updates == 2 {
+				loop.Stop()
+			}
+		}).
+		SetRenderFunc(func() {
+			renders++
+		})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if updates != 2 {
+		t.Fatalf("update ran after stop: got %v updates, wanted 2", updates)
+	}
+
+	if renders != 2 {
+		t.Fatalf("stopping frame did not complete: got %v renders, wanted 2", renders)
+	}
+
+	if loop.GetElapsed() != 100*time.Millisecond || clock.Since(time.Unix(0, 0)) != 100*time.Millisecond {
+		t.Fatalf("stopping frame slept: clock at %v", clock.Since(time.Unix(0, 0)))
+	}
+}
+
+func TestTickSource(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	ticks := make(chan time.Time, 30)
+	for i := 0; i < cap(ticks); i++ {
+		ticks <- time.Time{}
+	}
+	close(ticks)
+
+	frames := 0
+	loop := gyro.NewLoop().
+		SetTargetFps(60).
+		SetClock(clock).
+		SetTickSource(ticks).
+		SetUpdateFunc(func(dt time.Duration) {
+			// Ticks arrive every 50ms, the loop itself never sleeps
+			frames++
+			clock.Advance(50 * time.Millisecond)
+		})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if frames != 30 {
+		t.Fatalf("got %v frames, wanted one per tick (30)", frames)
+	}
+
+	if loop.GetCurrentFps() != 20 {
+		t.Fatalf("got %v fps, wanted the tick rate (20)", loop.GetCurrentFps())
+	}
+}
+
+func TestTimeRemaining(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	var before, after time.Duration
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(clock).
+		SetUpdateFunc(func(dt time.Duration) {
+			before = loop.TimeRemaining()
+			clock.Advance(130 * time.Millisecond)
+			after = loop.TimeRemaining()
+			loop.Stop()
+		})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if before != 100*time.Millisecond || after != -30*time.Millisecond {
+		t.Fatalf("got %v then %v remaining, wanted 100ms then -30ms", before, after)
+	}
+
+	if loop.TimeRemaining() != 0 {
+		t.Fatalf("got %v remaining after stop, wanted 0", loop.TimeRemaining())
+	}
+}
+
+func TestFrameTimeStats(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	var loop *gyro.Loop
+
+	// Frames alternate between 100ms and 160ms
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(clock).
+		SetFpsSampleWindow(time.Second).
+		SetUpdateFunc(func(dt time.Duration) {
+			count := loop.GetFrameCount()
+			if count%2 == 1 {
+				clock.Advance(160 * time.Millisecond)
+			}
+			if count == 30 {
+				loop.Stop()
+			}
+		})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	min, max, avg, stddev := loop.GetFrameTimeStats()
+	if min != 100*time.Millisecond || max != 160*time.Millisecond || avg != 130*time.Millisecond || stddev != 30*time.Millisecond {
+		t.Fatalf("got min %v, max %v, avg %v, stddev %v, wanted 100ms, 160ms, 130ms, 30ms", min, max, avg, stddev)
+	}
+
+	if loop.Snapshot().FrameTimes.Avg != avg {
+		t.Fatalf("snapshot frame times don't match the getter")
+	}
+}
+
+func TestConcurrentRender(t *testing.T) {
+	release := make(chan struct{})
+	var renders atomic.Int32
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(60).
+		SetConcurrentRender(true).
+		SetUpdateFunc(func(dt time.Duration) {
+			switch loop.GetFrameCount() {
+			case 1:
+				// Only reachable while the first render is still blocked
+				close(release)
+			case 3:
+				loop.Stop()
+			}
+		}).
+		SetRenderFunc(func() {
+			if renders.Add(1) == 1 {
+				select {
+				case <-release:
+				case <-time.After(time.Second):
+					t.Errorf("render did not overlap with the next update")
+				}
+			}
+		})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if renders.Load() != 4 {
+		t.Fatalf("got %v renders before Start returned, wanted 4", renders.Load())
+	}
+}
+
+func TestConcurrentRenderPanic(t *testing.T) {
+	var recovered any
+
+	loop := gyro.NewLoop().
+		SetConcurrentRender(true).
+		SetUpdateFunc(func(dt time.Duration) {}).
+		SetRenderFunc(func() {
+			panic("render failed")
+		}).
+		SetRecoverFunc(func(r any) {
+			recovered = r
+		})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if recovered != "render failed" || loop.IsRunning() {
+		t.Fatalf("render panic not raised on the loop goroutine: recovered %v", recovered)
+	}
+}
+
+func TestWarmup(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	var samples []int
+	var warmupFrame uint64
+	var loop *gyro.Loop
+
+	// The first frames stall like a run still loading assets
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(clock).
+		SetWarmupFrames(5).
+		SetUpdateFunc(func(dt time.Duration) {
+			if loop.GetFrameCount() < 5 {
+				clock.Advance(400 * time.Millisecond)
+			}
+		}).
+		SetOnWarmupComplete(func() {
+			warmupFrame = loop.GetFrameCount()
+		}).
+		SetOnFpsSample(func(fps int) {
+			samples = append(samples, fps)
+			if len(samples) == 2 {
+				loop.Stop()
+			}
+		})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if warmupFrame != 5 {
+		t.Fatalf("warmup completed after %v frames, wanted 5", warmupFrame)
+	}
+
+	if !slices.Equal(samples, []int{10, 10}) {
+		t.Fatalf("got fps samples %v, wanted the warmup left out", samples)
+	}
+}
+
+func TestGetCurrentFpsFloat(t *testing.T) {
+	fps := 59.94
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFpsFloat(fps).
+		SetClock(gyro.NewFakeClock()).
+		SetUpdateFunc(func(dt time.Duration) {
+			if loop.GetFrameCount() == 100 {
+				loop.Stop()
+			}
+		})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if math.Abs(loop.GetCurrentFpsFloat()-fps) > 0.001 {
+		t.Fatalf("got %v fps, wanted %v", loop.GetCurrentFpsFloat(), fps)
+	}
+}
+
+func TestRenderPanicAfterStop(t *testing.T) {
+	stopped := false
+	var recovered []any
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(60).
+		SetRecoverFunc(func(r any) {
+			recovered = append(recovered, r)
+		}).
+		SetUpdateFunc(func(dt time.Duration) {
+			if loop.GetFrameCount() == 2 {
+				loop.Pause()
+				loop.Stop()
+				stopped = true
+			}
+		}).
+		SetRenderFunc(func() {
+			if stopped {
+				panic("render after resources were freed")
+			}
+		})
+
+	for run := 1; run <= 2; run++ {
+		stopped = false
+		err := loop.Start()
+		if err != nil {
+			t.Fatalf("run %v: failed to start: %q", run, err.Error())
+		}
+
+		if loop.IsRunning() || loop.IsPaused() {
+			t.Fatalf("run %v: inconsistent state after stop: running %v, paused %v", run, loop.IsRunning(), loop.IsPaused())
+		}
+
+		if loop.GetFrameCount() != 3 {
+			t.Fatalf("run %v: got %v frames, wanted 3", run, loop.GetFrameCount())
+		}
+	}
+
+	if len(recovered) != 2 {
+		t.Fatalf("got %v recovered panics, wanted one per run", len(recovered))
+	}
+}
+
+func TestPanicAfterStopWithoutRecover(t *testing.T) {
+	startPanic := func(loop *gyro.Loop) (r any) {
+		defer func() {
+			r = recover()
+		}()
+		loop.Start()
+		return nil
+	}
+
+	// A render panic in the frame calling Stop
+	var loop *gyro.Loop
+	loop = gyro.NewLoop().
+		SetTargetFps(60).
+		SetClock(gyro.NewFakeClock()).
+		SetUpdateFunc(func(dt time.Duration) {
+			loop.Stop()
+		}).
+		SetRenderFunc(func() {
+			panic("render after stop")
+		})
+
+	if r := startPanic(loop); r != "render after stop" {
+		t.Fatalf("got %v from Start, wanted the render panic", r)
+	}
+
+	// The run state is reset before the panic is rethrown
+	if loop.IsRunning() || loop.IsStopping() {
+		t.Fatalf("got running %v and stopping %v after the panic", loop.IsRunning(), loop.IsStopping())
+	}
+
+	// An update panic in the drain frame of StopAndDrain, e.g. while saving
+	draining := false
+	loop = gyro.NewLoop().
+		SetTargetFps(60).
+		SetClock(gyro.NewFakeClock()).
+		SetUpdateFunc(func(dt time.Duration) {
+			if draining {
+				panic("save failed")
+			}
+			draining = true
+			loop.StopAndDrain()
+		})
+
+	if r := startPanic(loop); r != "save failed" {
+		t.Fatalf("got %v from Start, wanted the drain update panic", r)
+	}
+}
+
+func TestChangeTargetFpsWhileRunning(t *testing.T) {
+	loop := gyro.NewLoop().
+		SetTargetFps(20).
+		SetFpsSampleWindow(500 * time.Millisecond).
+		SetUpdateFunc(func(dt time.Duration) {})
+
+	go func() {
+		time.Sleep(1200 * time.Millisecond)
+		if fps := loop.GetCurrentFps(); fps < 18 || fps > 22 {
+			t.Errorf("got %v fps before the change, wanted ~20", fps)
+		}
+
+		loop.SetTargetFps(50)
+		time.Sleep(1100 * time.Millisecond)
+		loop.Stop()
+	}()
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	// The last full sample window ran after the change
+	if fps := loop.GetCurrentFps(); fps < 45 || fps > 55 {
+		t.Fatalf("got %v fps after the change, wanted ~50", fps)
+	}
+}
+
+func TestSimulationOnly(t *testing.T) {
+	err := gyro.NewLoop().
+		SetSimulationOnly(true).
+		SetUpdateFunc(func(dt time.Duration) {}).
+		SetRenderFunc(func() {}).
+		Validate()
+	if !errors.Is(err, gyro.ErrRenderSimulation) {
+		t.Fatalf("got %v, wanted %v", err, gyro.ErrRenderSimulation)
+	}
+
+	var deltas []time.Duration
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(gyro.NewFakeClock()).
+		SetSimulationOnly(true).
+		SetUpdateFunc(func(dt time.Duration) {
+			deltas = append(deltas, dt)
+			if len(deltas) == 3 {
+				loop.Stop()
+			}
+		})
+
+	err = loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if !slices.Equal(deltas, []time.Duration{0, 100 * time.Millisecond, 100 * time.Millisecond}) {
+		t.Fatalf("got deltas %v, wanted paced 100ms updates", deltas)
+	}
+}
+
+func TestDone(t *testing.T) {
+	loop := gyro.NewLoop().
+		SetTargetFps(60).
+		SetUpdateFunc(func(dt time.Duration) {})
+
+	for run := 1; run <= 2; run++ {
+		done, err := loop.StartAsync()
+		if err != nil {
+			t.Fatalf("run %v: failed to start: %q", run, err.Error())
+		}
+
+		var waiters sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			waiters.Add(1)
+			go func() {
+				defer waiters.Done()
+				<-loop.Done()
+			}()
+		}
+
+		select {
+		case <-loop.Done():
+			t.Fatalf("run %v: done closed while running", run)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		loop.Stop()
+		<-done
+
+		unblocked := make(chan struct{})
+		go func() {
+			waiters.Wait()
+			close(unblocked)
+		}()
+
+		select {
+		case <-unblocked:
+		case <-time.After(time.Second):
+			t.Fatalf("run %v: waiters did not unblock on done", run)
+		}
+	}
+}
+
+func TestRenderAsyncDrop(t *testing.T) {
+	release := make(chan struct{})
+	var renders atomic.Int32
+	var dropped int
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(100).
+		SetRenderAsyncDrop(true).
+		SetUpdateFunc(func(dt time.Duration) {
+			switch loop.GetFrameCount() {
+			case 5:
+				// Updates kept running while the first render blocked
+				close(release)
+			case 10:
+				loop.Stop()
+			}
+		}).
+		SetRenderFunc(func() {
+			if renders.Add(1) == 1 {
+				<-release
+			}
+		}).
+		SetStatsFunc(func(stats gyro.FrameStats) {
+			if stats.RenderDropped {
+				dropped++
+			}
+		})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if loop.GetDroppedRenders() < 4 || loop.GetDroppedRenders() != uint64(dropped) {
+		t.Fatalf("got %v dropped renders (%v in stats), wanted at least 4", loop.GetDroppedRenders(), dropped)
+	}
+
+	if int(renders.Load())+dropped != 11 {
+		t.Fatalf("got %v renders and %v dropped, wanted 11 in total", renders.Load(), dropped)
+	}
+}
+
+func BenchmarkSleepStrategy(b *testing.B) {
+	strategies := []struct {
+		name     string
+		strategy gyro.SleepStrategy
+	}{
+		{"os", gyro.SLEEP_OS},
+		{"hybrid", gyro.SLEEP_HYBRID},
+		{"busy_wait", gyro.SLEEP_BUSY_WAIT},
+	}
+
+	for _, s := range strategies {
+		b.Run(s.name, func(b *testing.B) {
+			var loop *gyro.Loop
+			var last time.Time
+			var jitter time.Duration
+			frames := 0
+
+			loop = gyro.NewLoop().
+				SetTargetFps(500).
+				SetSleepStrategy(s.strategy).
+				SetUpdateFunc(func(dt time.Duration) {
+					now := time.Now()
+					if frames > 0 {
+						jitter += (now.Sub(last) - loop.GetTargetPeriod()).Abs()
+					}
+
+					last = now
+					frames++
+					if frames > b.N {
+						loop.Stop()
+					}
+				})
+
+			b.ResetTimer()
+			if err := loop.Start(); err != nil {
+				b.Fatalf("failed to start: %q", err.Error())
+			}
+
+			// Mean deviation of the frame period from the target period
+			b.ReportMetric(float64(jitter)/float64(b.N), "ns-jitter/frame")
+		})
+	}
+}
+
+func TestIsStopping(t *testing.T) {
+	type state struct{ running, stopping bool }
+	var states []state
+	var loop *gyro.Loop
+
+	record := func() {
+		states = append(states, state{loop.IsRunning(), loop.IsStopping()})
+	}
+
+	loop = gyro.NewLoop().
+		SetTargetFps(60).
+		SetUpdateFunc(func(dt time.Duration) {
+			record()
+			if len(states) == 1 {
+				loop.StopAndDrain()
+			}
+		})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+	record()
+
+	// Running, then stopping during the drain frame, then stopped
+	want := []state{{true, false}, {false, true}, {false, false}}
+	if !slices.Equal(states, want) {
+		t.Fatalf("got states %v, wanted %v", states, want)
+	}
+}
+
+func TestRenderLayers(t *testing.T) {
+	var calls []string
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(60).
+		SetUpdateFunc(func(dt time.Duration) {
+			switch loop.GetFrameCount() {
+			case 0:
+				// Takes effect from the next frame on
+				loop.RemoveRenderLayer(-10)
+			case 1:
+				loop.Stop()
+			}
+		}).
+		AddRenderLayer(10, func() {
+			calls = append(calls, "hud")
+		}).
+		AddRenderLayer(-10, func() {
+			calls = append(calls, "background")
+		}).
+		SetRenderFunc(func() {
+			calls = append(calls, "game")
+		})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	want := []string{"background", "game", "hud", "game", "hud"}
+	if !slices.Equal(calls, want) {
+		t.Fatalf("got calls %v, wanted %v", calls, want)
+	}
+}
+
+func TestRunFrames(t *testing.T) {
+	updates := 0
+	loop := gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(gyro.NewFakeClock()).
+		SetUpdateFunc(func(dt time.Duration) {
+			updates++
+		})
+
+	err := loop.RunFrames(5)
+	if err != nil {
+		t.Fatalf("failed to run: %q", err.Error())
+	}
+
+	if updates != 5 || loop.GetFrameCount() != 5 || loop.GetElapsed() != 400*time.Millisecond {
+		t.Fatalf("got %v updates, %v frames in %v, wanted 5 in 400ms", updates, loop.GetFrameCount(), loop.GetElapsed())
+	}
+
+	updates = 0
+	err = loop.SetMaxFrames(3).Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if updates != 3 {
+		t.Fatalf("got %v updates, wanted the max of 3 frames", updates)
+	}
+
+	// No frames to run, so the loop doesn't start
+	for _, n := range []int{0, -1} {
+		if err := loop.RunFrames(n); err != nil || updates != 3 || loop.GetFrameCount() != 3 {
+			t.Fatalf("RunFrames(%v): got %v with %v updates, %v frames, wanted nil and no frame", n, err, updates, loop.GetFrameCount())
+		}
+	}
+}
+
+func TestUpdateFuncErr(t *testing.T) {
+	errGameOver := errors.New("game over")
+	updates := 0
+
+	loop := gyro.NewLoop().
+		SetTargetFps(60).
+		SetUpdateFuncErr(func(dt time.Duration) error {
+			updates++
+			if updates == 3 {
+				return errGameOver
+			}
+			return nil
+		})
+
+	err := loop.Start()
+	if !errors.Is(err, errGameOver) {
+		t.Fatalf("got %v, wanted %v", err, errGameOver)
+	}
+
+	if updates != 3 || loop.IsRunning() {
+		t.Fatalf("loop didn't stop on the update error: got %v updates", updates)
+	}
+
+	// The error only belongs to the run it stopped
+	updates = 10
+	err = loop.RunFrames(2)
+	if err != nil {
+		t.Fatalf("got %v from the next run, wanted nil", err)
+	}
+}
+
+func TestTargetFpsFunc(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	onBattery := false
+	var samples []int
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetClock(clock).
+		SetTargetFpsFunc(func() int {
+			if onBattery {
+				return 30
+			}
+			return 60
+		}).
+		SetUpdateFunc(func(dt time.Duration) {}).
+		SetOnFpsSample(func(fps int) {
+			samples = append(samples, fps)
+			onBattery = true
+			if len(samples) == 3 {
+				loop.Stop()
+			}
+		})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if !slices.Equal(samples, []int{60, 30, 30}) || loop.GetTargetFps() != 30 {
+		t.Fatalf("got fps samples %v with target %v, wanted 60 then 30", samples, loop.GetTargetFps())
+	}
+}
+
+func TestGetLastDelta(t *testing.T) {
+	loop := gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(gyro.NewFakeClock()).
+		SetTimeScale(0.5).
+		SetUpdateFunc(func(dt time.Duration) {})
+
+	err := loop.RunFrames(3)
+	if err != nil {
+		t.Fatalf("failed to run: %q", err.Error())
+	}
+
+	if loop.GetLastDelta() != 50*time.Millisecond || loop.GetLastRawDelta() != 100*time.Millisecond {
+		t.Fatalf("got last delta %v and raw %v, wanted 50ms and 100ms", loop.GetLastDelta(), loop.GetLastRawDelta())
+	}
+}
+
+func TestInputFuncBool(t *testing.T) {
+	inputs, updates, renders := 0, 0, 0
+
+	loop := gyro.NewLoop().
+		SetTargetFps(60).
+		SetInputFuncBool(func() bool {
+			inputs++
+			return inputs < 3
+		}).
+		SetUpdateFunc(func(dt time.Duration) {
+			updates++
+		}).
+		SetRenderFunc(func() {
+			renders++
+		})
+
+	err := loop.Start()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if inputs != 3 || updates != 2 || renders != 2 {
+		t.Fatalf("got %v inputs, %v updates and %v renders, wanted the quit frame to stop after input", inputs, updates, renders)
+	}
+}
+
+func TestConcurrentStop(t *testing.T) {
+	var stops atomic.Int32
+
+	loop := gyro.NewLoop().
+		SetTargetFps(120).
+		SetUpdateFunc(func(dt time.Duration) {}).
+		SetOnStop(func() {
+			stops.Add(1)
+		})
+
+	for run := 1; run <= 20; run++ {
+		done, err := loop.StartAsync()
+		if err != nil {
+			t.Fatalf("run %v: failed to start: %q", run, err.Error())
+		}
+
+		// Many goroutines race to stop the same run, e.g. SIGINT and a window close
+		var stoppers sync.WaitGroup
+		start := make(chan struct{})
+		for i := 0; i < 50; i++ {
+			stoppers.Add(1)
+			go func(i int) {
+				defer stoppers.Done()
+				<-start
+				if i%2 == 0 {
+					loop.Stop()
+				} else {
+					loop.StopAndDrain()
+				}
+			}(i)
+		}
+
+		close(start)
+		stoppers.Wait()
+		if err := <-done; err != nil {
+			t.Fatalf("run %v: loop exited with %q", run, err.Error())
+		}
+	}
+
+	if stops.Load() != 20 {
+		t.Fatalf("got %v shutdowns, wanted exactly one per run (20)", stops.Load())
+	}
+}
+
+func TestFrameHooks(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	var calls []string
+	var after []gyro.Frame
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(clock).
+		SetBeforeFrame(func(frame gyro.Frame) {
+			calls = append(calls, fmt.Sprintf("before %v", frame.Number))
+		}).
+		SetAfterFrame(func(frame gyro.Frame) {
+			calls = append(calls, fmt.Sprintf("after %v", frame.Number))
+			after = append(after, frame)
+		}).
+		SetUpdateFunc(func(dt time.Duration) {
+			calls = append(calls, "update")
+		})
+
+	err := loop.RunFrames(2)
+	if err != nil {
+		t.Fatalf("failed to run: %q", err.Error())
+	}
+
+	want := []string{"before 0", "update", "after 0", "before 1", "update", "after 1"}
+	if !slices.Equal(calls, want) {
+		t.Fatalf("got calls %v, wanted %v", calls, want)
+	}
+
+	// The after hook reports the total delta time of the frame
+	if after[1].Delta != 100*time.Millisecond {
+		t.Fatalf("got after frame %+v, wanted a 100ms delta", after[1])
+	}
+}
+
+func TestOnSpiral(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	spirals := 0
+	var loop *gyro.Loop
+
+	// Every frame overruns its 100ms budget by far
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(clock).
+		SetFixedTimestep(10 * time.Millisecond).
+		SetSpiralFrames(3).
+		SetOnSpiral(func() {
+			spirals++
+		}).
+		SetUpdateFunc(func(dt time.Duration) {
+			clock.Advance(50 * time.Millisecond)
+		}).
+		SetRenderFunc(func() {
+			clock.Advance(200 * time.Millisecond)
+		})
+
+	// The last frame stops the loop, so it has no budget to overrun
+	err := loop.RunFrames(10)
+	if err != nil {
+		t.Fatalf("failed to run: %q", err.Error())
+	}
+
+	if spirals != 3 {
+		t.Fatalf("got %v spirals, wanted one every 3 overrun frames (3)", spirals)
+	}
+}
+
+func TestFpsCounter(t *testing.T) {
+	counters := map[string]gyro.FpsCounter{
+		"window": gyro.NewWindowFpsCounter(500 * time.Millisecond),
+		"ema":    gyro.NewEmaFpsCounter(0.5),
+	}
+
+	for name, counter := range counters {
+		loop := gyro.NewLoop().
+			SetTargetFps(25).
+			SetClock(gyro.NewFakeClock()).
+			SetFpsCounter(counter).
+			SetUpdateFunc(func(dt time.Duration) {})
+
+		err := loop.RunFrames(20)
+		if err != nil {
+			t.Fatalf("%v: failed to run: %q", name, err.Error())
+		}
+
+		// The default sample window of a second never completed
+		if loop.GetCurrentFps() != 25 || math.Round(counter.Value()) != 25 {
+			t.Fatalf("%v: got %v fps, wanted 25 from the counter", name, loop.GetCurrentFps())
+		}
+	}
+}
+
+func TestQualityController(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	work := 10 * time.Millisecond
+	var tiers []int
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(clock).
+		SetFpsSampleWindow(500 * time.Millisecond).
+		SetQualityController(gyro.NewQualityController(0, 2)).
+		SetOnQualityChange(func(tier int) {
+			tiers = append(tiers, tier)
+			if tier == 2 {
+				// The scene gets heavier once quality is maxed out
+				work = 95 * time.Millisecond
+			}
+		}).
+		SetUpdateFunc(func(dt time.Duration) {
+			clock.Advance(work)
+		})
+
+	err := loop.RunFrames(40)
+	if err != nil {
+		t.Fatalf("failed to run: %q", err.Error())
+	}
+
+	if !slices.Equal(tiers, []int{1, 2, 1, 0}) || loop.GetQualityTier() != 0 {
+		t.Fatalf("got tier changes %v, wanted 1, 2, 1, 0", tiers)
+	}
+}
+
+func TestUpdateFuncRealScaled(t *testing.T) {
+	var scaled, real []time.Duration
+
+	loop := gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(gyro.NewFakeClock()).
+		SetTimeScale(0.5).
+		SetUpdateFuncRealScaled(func(s, r time.Duration) {
+			scaled = append(scaled, s)
+			real = append(real, r)
+		})
+
+	err := loop.RunFrames(3)
+	if err != nil {
+		t.Fatalf("failed to run: %q", err.Error())
+	}
+
+	if scaled[2] != 50*time.Millisecond || real[2] != 100*time.Millisecond {
+		t.Fatalf("got scaled %v and real %v, wanted 50ms and 100ms", scaled, real)
+	}
+
+	// Fixed updates get the real time each scaled timestep stands for
+	scaled, real = nil, nil
+	err = loop.SetFixedTimestep(25 * time.Millisecond).RunFrames(3)
+	if err != nil {
+		t.Fatalf("failed to run: %q", err.Error())
+	}
+
+	if len(scaled) != 4 || scaled[0] != 25*time.Millisecond || real[0] != 50*time.Millisecond {
+		t.Fatalf("got scaled %v and real %v, wanted 25ms and 50ms steps", scaled, real)
+	}
+}
+
+func BenchmarkFrameAllocs(b *testing.B) {
+	const warmup = 10
+	var before, after runtime.MemStats
+	var loop *gyro.Loop
+
+	// Frames are measured between the warmup and the last frame, leaving out
+	// the allocations made when starting and stopping the loop
+	loop = gyro.NewLoop().
+		SetClock(gyro.NewFakeClock()).
+		SetUncapped(true).
+		SetUpdateFuncCtx(func(frame gyro.Frame) {
+			switch frame.Number {
+			case warmup:
+				runtime.ReadMemStats(&before)
+				b.ResetTimer()
+			case warmup + uint64(b.N):
+				b.StopTimer()
+				runtime.ReadMemStats(&after)
+				loop.Stop()
+			}
+		}).
+		SetRenderFuncCtx(func(frame gyro.Frame) {}).
+		SetStatsFunc(func(stats gyro.FrameStats) {})
+
+	b.ReportAllocs()
+	if err := loop.Start(); err != nil {
+		b.Fatalf("failed to start: %q", err.Error())
+	}
+
+	allocs := float64(after.Mallocs-before.Mallocs) / float64(b.N)
+	b.ReportMetric(allocs, "allocs/frame")
+	if allocs > 0 {
+		b.Fatalf("got %v allocs per frame, wanted 0", allocs)
+	}
+}
+
+func TestRenderOncePerFrame(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	frames, updates, renders := 0, 0, 0
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(clock).
+		SetFixedTimestep(20 * time.Millisecond).
+		SetRenderOncePerFrame(true)
+
+	// Every frame takes 5 updates worth of time, so updates are always behind
+	loop.SetInputFunc(func() {
+		clock.Advance(100 * time.Millisecond)
+		frames++
+	}).SetUpdateFunc(func(dt time.Duration) {
+		updates++
+	}).SetRenderFunc(func() {
+		if frames == 10 {
+			loop.Stop()
+		}
+
+		// The time advanced by input is caught up on by the next frame
+		renders++
+		if updates != (frames-1)*5 {
+			t.Errorf("got render after %v updates on frame %v, wanted %v", updates, frames, (frames-1)*5)
+		}
+	})
+
+	if err := loop.Start(); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if renders != frames {
+		t.Fatalf("got %v renders in %v frames, wanted exactly one per frame", renders, frames)
+	}
+
+	err := loop.SetMaxRenderSkip(2).Validate()
+	if !errors.Is(err, gyro.ErrRenderOnceSkip) {
+		t.Fatalf("got %v, wanted %v", err, gyro.ErrRenderOnceSkip)
+	}
+}
+
+func TestSystemStats(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	var systems []gyro.SystemStats
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetClock(clock).
+		SetUncapped(true).
+		AddSystem("physics", func(dt time.Duration) {
+			clock.Advance(3 * time.Millisecond)
+		}).
+		AddSystem("ai", func(dt time.Duration) {
+			clock.Advance(5 * time.Millisecond)
+		}).
+		SetStatsFunc(func(stats gyro.FrameStats) {
+			systems = slices.Clone(stats.Systems)
+			loop.Stop()
+		})
+
+	if err := loop.Start(); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	want := []gyro.SystemStats{
+		{Name: "physics", Update: 3 * time.Millisecond},
+		{Name: "ai", Update: 5 * time.Millisecond},
+	}
+	if !slices.Equal(systems, want) {
+		t.Fatalf("got %v, wanted %v", systems, want)
+	}
+}
+
+func TestPanicLimit(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	updates, panics := 0, 0
+	var last gyro.PanicInfo
+
+	loop := gyro.NewLoop().
+		SetClock(clock).
+		SetUncapped(true).
+		SetIsolatePanics(true).
+		SetPanicLimit(3, time.Second).
+		SetRecoverFunc(func(r any) {
+			panics++
+		}).
+		SetOnPanicLimit(func(info gyro.PanicInfo) {
+			last = info
+		})
+
+	// Panics spread over more than the window don't count towards the limit
+	loop.SetUpdateFunc(func(dt time.Duration) {
+		updates++
+		if updates <= 2 {
+			clock.Advance(2 * time.Second)
+		}
+		panic("update failed")
+	})
+
+	err := loop.Start()
+	if !errors.Is(err, gyro.ErrPanicLimit) {
+		t.Fatalf("got %v, wanted %v", err, gyro.ErrPanicLimit)
+	}
+
+	if panics != 4 {
+		t.Fatalf("got %v panics, wanted 4", panics)
+	}
+
+	if last.Phase != gyro.PHASE_UPDATE || last.Value != "update failed" {
+		t.Fatalf("got last panic %v, wanted the update panic", last)
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	tests := []struct {
+		interpolation gyro.Interpolation
+		targetFps     int
+		wantAlpha     bool
+	}{
+		{gyro.INTERPOLATION_ALWAYS, 10, true},
+		{gyro.INTERPOLATION_AUTO, 10, false},
+		{gyro.INTERPOLATION_AUTO, 100, true},
+		{gyro.INTERPOLATION_NEVER, 100, false},
+	}
+
+	for _, test := range tests {
+		clock := gyro.NewFakeClock()
+		frames := 0
+		sawAlpha := false
+		var loop *gyro.Loop
+
+		// Frames take 110ms, longer than either frame period, so renders land
+		// halfway between two 20ms updates
+		loop = gyro.NewLoop().
+			SetClock(clock).
+			SetTargetFps(test.targetFps).
+			SetFixedTimestep(20 * time.Millisecond).
+			SetInterpolation(test.interpolation).
+			SetInputFunc(func() {
+				clock.Advance(110 * time.Millisecond)
+			}).
+			SetUpdateFunc(func(dt time.Duration) {}).
+			SetRenderFuncAlpha(func(alpha float64) {
+				sawAlpha = sawAlpha || alpha > 0
+				frames++
+				if frames == 5 {
+					loop.Stop()
+				}
+			})
+
+		if err := loop.Start(); err != nil {
+			t.Fatalf("failed to start: %q", err.Error())
+		}
+
+		if sawAlpha != test.wantAlpha {
+			t.Fatalf("interpolation %v at %v fps: got alpha %v, wanted %v", test.interpolation, test.targetFps, sawAlpha, test.wantAlpha)
+		}
+	}
+}
+
+func TestGetConfig(t *testing.T) {
+	loop := gyro.NewLoop().
+		SetTargetFpsFloat(59.94).
+		SetFixedTimestep(20 * time.Millisecond).
+		SetMaxDeltaTime(100 * time.Millisecond).
+		SetTimeScale(0.5).
+		SetCatchUpPolicy(gyro.CATCH_UP).
+		SetIsolatePanics(true).
+		SetInputOnMainThread(true)
+
+	config := loop.GetConfig()
+	if config.TargetFps != 59.94 || config.FixedTimestep != 20*time.Millisecond || config.MaxDeltaTime != 100*time.Millisecond ||
+		config.TimeScale != 0.5 || config.CatchUpPolicy != gyro.CATCH_UP || !config.IsolatePanics || !config.InputOnMainThread {
+		t.Fatalf("unexpected config: %+v", config)
+	}
+
+	if config.MaxUpdatesPerFrame != gyro.DEFAULT_MAX_UPDATES_PER_FRAME || config.FpsSampleWindow != gyro.DEFAULT_FPS_SAMPLE_WINDOW ||
+		!slices.Equal(config.HistogramBuckets, loop.GetHistogram().Bounds) {
+		t.Fatalf("got config %+v, wanted the defaults for unset parameters", config)
+	}
+
+	// The config is a copy
+	config.TargetFps = 30
+	if loop.GetTargetFpsFloat() != 59.94 {
+		t.Fatalf("changing the config changed the loop target fps to %v", loop.GetTargetFpsFloat())
+	}
+	config.HistogramBuckets[0] = time.Second
+	if bounds := loop.GetHistogram().Bounds; bounds[0] == time.Second {
+		t.Fatalf("changing the config changed the loop histogram buckets to %v", bounds)
+	}
+}
+
+func TestNewLoopFromConfig(t *testing.T) {
+	config := gyro.DefaultConfig()
+	config.TargetFps = 30
+	config.FixedTimestep = 20 * time.Millisecond
+	config.TimeScale = 2
+	config.InputOnMainThread = true
+	config.HistogramBuckets = []time.Duration{5 * time.Millisecond, 10 * time.Millisecond}
+
+	loop, err := gyro.NewLoopFromConfig(config)
+	if err != nil {
+		t.Fatalf("failed to create loop: %q", err.Error())
+	}
+
+	if got := loop.GetConfig(); !reflect.DeepEqual(got, config) {
+		t.Fatalf("got config %+v, wanted %+v", got, config)
+	}
+
+	// Functions are set afterwards
+	loop.SetUpdateFunc(func(dt time.Duration) {
+		loop.Stop()
+	})
+	if err := loop.Start(); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	config.Uncapped = true
+	if _, err := gyro.NewLoopFromConfig(config); !errors.Is(err, gyro.ErrFixedUncapped) {
+		t.Fatalf("got %v, wanted %v", err, gyro.ErrFixedUncapped)
+	}
+}
+
+func TestLastFrameHadHeadroom(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	var headroom []bool
+	var sleeps []time.Duration
+	var loop *gyro.Loop
+
+	// Frames alternate between 30ms and 130ms of work at 10 fps
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(clock).
+		SetUpdateFunc(func(dt time.Duration) {
+			if frame := loop.GetFrameCount(); frame > 0 {
+				headroom = append(headroom, loop.LastFrameHadHeadroom())
+				sleeps = append(sleeps, loop.LastSleepDuration())
+			}
+			if loop.GetFrameCount()%2 == 0 {
+				clock.Advance(30 * time.Millisecond)
+			} else {
+				clock.Advance(130 * time.Millisecond)
+			}
+			if loop.GetFrameCount() == 4 {
+				loop.Stop()
+			}
+		})
+
+	if err := loop.Start(); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	wantHeadroom := []bool{true, false, true, false}
+	wantSleeps := []time.Duration{70 * time.Millisecond, 0, 70 * time.Millisecond, 0}
+	if !slices.Equal(headroom, wantHeadroom) || !slices.Equal(sleeps, wantSleeps) {
+		t.Fatalf("got headroom %v and sleeps %v, wanted %v and %v", headroom, sleeps, wantHeadroom, wantSleeps)
+	}
+}
+
+func TestRecoverFuncDecide(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	updates := 0
+	var recovered []any
+
+	loop := gyro.NewLoop().
+		SetClock(clock).
+		SetTargetFps(10).
+		SetRecoverFuncDecide(func(r any) bool {
+			recovered = append(recovered, r)
+			return len(recovered) < 2
+		})
+
+	loop.SetUpdateFunc(func(dt time.Duration) {
+		updates++
+		if updates == 2 || updates == 4 {
+			// The frame time lost to the panic isn't handed to the next update
+			clock.Advance(time.Second)
+			panic(updates)
+		}
+
+		if updates == 3 && dt >= time.Second {
+			t.Errorf("got delta %v after the recovered panic, wanted it to leave out the panicked frame", dt)
+		}
+	})
+
+	if err := loop.Start(); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if !slices.Equal(recovered, []any{2, 4}) || updates != 4 {
+		t.Fatalf("got recovered %v after %v updates, wanted [2 4] after 4", recovered, updates)
+	}
+}
+
+func TestMinFrameTime(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	var sleeps []time.Duration
+	overruns := 0
+	var loop *gyro.Loop
+
+	// An uncapped loop only sleeps on frames shorter than the floor
+	loop = gyro.NewLoop().
+		SetClock(clock).
+		SetUncapped(true).
+		SetMinFrameTime(20 * time.Millisecond).
+		SetOnFrameOverrun(func(over time.Duration) {
+			overruns++
+		}).
+		SetUpdateFunc(func(dt time.Duration) {
+			if loop.GetFrameCount()%2 == 0 {
+				clock.Advance(5 * time.Millisecond)
+			} else {
+				clock.Advance(50 * time.Millisecond)
+			}
+			if loop.GetFrameCount() == 3 {
+				loop.Stop()
+			}
+		}).
+		SetStatsFunc(func(stats gyro.FrameStats) {
+			sleeps = append(sleeps, stats.Sleep)
+		})
+
+	if err := loop.Start(); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	want := []time.Duration{15 * time.Millisecond, 0, 15 * time.Millisecond}
+	if !slices.Equal(sleeps[:3], want) || overruns != 0 {
+		t.Fatalf("got sleeps %v and %v overruns, wanted %v and none", sleeps, overruns, want)
+	}
+
+	// With a target fps, the longer of both is the frame budget
+	sleeps = nil
+	loop.SetUncapped(false).SetTargetFps(100)
+	if err := loop.Start(); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if sleeps[0] != 15*time.Millisecond {
+		t.Fatalf("got sleep %v at 100 fps, wanted the 20ms floor to apply", sleeps[0])
+	}
+}
+
+func TestLoopGroup(t *testing.T) {
+	group := gyro.NewLoopGroup()
+	for i := 0; i < 3; i++ {
+		group.Add(gyro.NewLoop().
+			SetTargetFps(100).
+			SetUpdateFunc(func(dt time.Duration) {}))
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- group.Start()
+	}()
+
+	for _, loop := range group.GetLoops() {
+		if !loop.WaitUntilRunning(time.Second) {
+			t.Fatal("loop of the group didn't start")
+		}
+	}
+
+	if err := group.Stop(); err != nil {
+		t.Fatalf("failed to stop: %q", err.Error())
+	}
+
+	select {
+	case <-group.Done():
+	default:
+		t.Fatal("group not done after Stop returned")
+	}
+
+	if err := <-result; err != nil {
+		t.Fatalf("got %v, wanted nil", err)
+	}
+
+	for _, loop := range group.GetLoops() {
+		if loop.IsRunning() {
+			t.Fatal("loop of the group still running after Stop returned")
+		}
+	}
+
+	// One loop failing stops the whole group
+	errFailed := errors.New("failed")
+	group.Add(gyro.NewLoop().
+		SetTargetFps(100).
+		SetUpdateFuncErr(func(dt time.Duration) error {
+			return errFailed
+		}))
+
+	if err := group.Start(); !errors.Is(err, errFailed) {
+		t.Fatalf("got %v, wanted %v", err, errFailed)
+	}
+}
+
+func TestRunFor(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	start := clock.Now()
+
+	loop := gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(clock).
+		SetUpdateFunc(func(dt time.Duration) {
+			clock.Advance(30 * time.Millisecond)
+		})
+
+	// Frames start every 100ms, the one due at 500ms would start past the deadline
+	if err := loop.RunFor(450 * time.Millisecond); err != nil {
+		t.Fatalf("got %v, wanted nil", err)
+	}
+
+	if frames, elapsed := loop.GetFrameCount(), clock.Since(start); frames != 5 || elapsed != 450*time.Millisecond {
+		t.Fatalf("got %v frames in %v, wanted 5 frames in 450ms", frames, elapsed)
+	}
+
+	// The deadline only applies to that run
+	loop.SetUpdateFunc(func(dt time.Duration) {
+		if loop.GetFrameCount() == 10 {
+			loop.Stop()
+		}
+	})
+	if err := loop.Start(); err != nil || loop.GetFrameCount() != 11 {
+		t.Fatalf("got %v after %v frames, wanted nil after 11", err, loop.GetFrameCount())
+	}
+}
+
+func TestOnFirstUpdate(t *testing.T) {
+	var calls []string
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(100).
+		SetClock(gyro.NewFakeClock()).
+		SetOnStart(func() {
+			calls = append(calls, "start")
+		}).
+		SetOnFirstUpdate(func() {
+			calls = append(calls, "first update")
+		}).
+		SetUpdateFunc(func(dt time.Duration) {
+			calls = append(calls, "update")
+		})
+
+	for i := 0; i < 2; i++ {
+		if err := loop.RunFrames(2); err != nil {
+			t.Fatalf("failed to start: %q", err.Error())
+		}
+	}
+
+	want := []string{"start", "first update", "update", "update", "start", "first update", "update", "update"}
+	if !slices.Equal(calls, want) {
+		t.Fatalf("got calls %v, wanted %v", calls, want)
+	}
+}
+
+func TestAddSystemRated(t *testing.T) {
+	var physics, ai []time.Duration
+
+	loop := gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(gyro.NewFakeClock()).
+		SetFixedTimestep(20*time.Millisecond).
+		AddSystem("physics", func(dt time.Duration) {
+			physics = append(physics, dt)
+		}).
+		AddSystemRated("ai", func(dt time.Duration) {
+			ai = append(ai, dt)
+		}, 3)
+
+	// 3 frames of 5 fixed updates each
+	if err := loop.RunFrames(4); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if len(physics) != 15 {
+		t.Fatalf("got %v physics updates, wanted 15", len(physics))
+	}
+
+	want := []time.Duration{60 * time.Millisecond, 60 * time.Millisecond, 60 * time.Millisecond, 60 * time.Millisecond, 60 * time.Millisecond}
+	if !slices.Equal(ai, want) {
+		t.Fatalf("got ai updates %v, wanted %v", ai, want)
+	}
+}
+
+func TestValidateConflicts(t *testing.T) {
+	tick := make(chan time.Time)
+	tests := []struct {
+		loop *gyro.Loop
+		want error
+	}{
+		{gyro.NewLoop().SetFixedTimestep(10 * time.Millisecond).SetUncapped(true), gyro.ErrFixedUncapped},
+		{gyro.NewLoop().SetTickSource(tick).SetUncapped(true), gyro.ErrTickUncapped},
+		{gyro.NewLoop().SetTickSource(tick).SetStepMode(true), gyro.ErrTickStepMode},
+		{gyro.NewLoop().SetTickSource(tick).SetTargetFps(30), gyro.ErrTickTargetFps},
+		{gyro.NewLoop().SetTickSource(tick).SetTargetFpsFunc(func() int { return 30 }), gyro.ErrTickTargetFps},
+	}
+
+	for _, test := range tests {
+		err := test.loop.SetUpdateFunc(func(dt time.Duration) {}).Start()
+		if !errors.Is(err, test.want) {
+			t.Fatalf("got %v from Start, wanted %v", err, test.want)
+		}
+	}
+
+	// A tick source with the default target fps is fine
+	if err := gyro.NewLoop().SetTickSource(tick).SetUpdateFunc(func(dt time.Duration) {}).Validate(); err != nil {
+		t.Fatalf("got %v for a tick source with the default target fps, wanted nil", err)
+	}
+}
+
+func TestDeltaEMA(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	var deltas []time.Duration
+	var loop *gyro.Loop
+
+	// A single 500ms hitch between two frames among 100ms frames, the frame
+	// schedule leaves out the sleep it took the place of
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(clock).
+		SetDeltaEMA(0.5).
+		SetUpdateFunc(func(dt time.Duration) {
+			deltas = append(deltas, dt)
+		}).
+		SetStatsFunc(func(stats gyro.FrameStats) {
+			if loop.GetFrameCount() == 4 {
+				clock.Advance(500 * time.Millisecond)
+			}
+		})
+
+	if err := loop.RunFrames(7); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	// The spike is halved, then decays back towards 100ms
+	want := []time.Duration{300 * time.Millisecond, 200 * time.Millisecond, 150 * time.Millisecond}
+	if !slices.Equal(deltas[4:], want) || deltas[3] != 100*time.Millisecond {
+		t.Fatalf("got deltas %v, wanted %v after 100ms frames", deltas, want)
+	}
+}
+
+func TestFrameEvents(t *testing.T) {
+	loop := gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(gyro.NewFakeClock()).
+		SetFrameEventsBuffer(3).
+		SetEmitFrameEvents(true).
+		SetUpdateFunc(func(dt time.Duration) {})
+
+	// Nobody reads the events while the loop runs, the loop still never blocks
+	if err := loop.RunFrames(10); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	events := loop.FrameEvents()
+	if len(events) != 3 || loop.GetDroppedFrameEvents() != 7 {
+		t.Fatalf("got %v events and %v dropped, wanted 3 and 7", len(events), loop.GetDroppedFrameEvents())
+	}
+
+	for i := uint64(0); i < 3; i++ {
+		if frame := <-events; frame.Number != i || frame.Elapsed != time.Duration(i)*100*time.Millisecond {
+			t.Fatalf("got event %+v, wanted frame %v", frame, i)
+		}
+	}
+
+	if loop.SetEmitFrameEvents(false).FrameEvents() != nil {
+		t.Fatal("got a frame events channel with frame events off")
+	}
+}
+
+func TestStopTimeout(t *testing.T) {
+	hanging, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	loop := gyro.NewLoop().
+		SetTargetFps(100).
+		SetUpdateFunc(func(dt time.Duration) {
+			once.Do(func() {
+				close(hanging)
+			})
+			<-release
+		})
+
+	if err := loop.StopTimeout(time.Millisecond); err != nil {
+		t.Fatalf("got %v stopping a loop that isn't running, wanted nil", err)
+	}
+
+	result, err := loop.StartAsync()
+	if err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	// The update hangs until released
+	<-hanging
+	if err := loop.StopTimeout(20 * time.Millisecond); !errors.Is(err, gyro.ErrStopTimeout) {
+		t.Fatalf("got %v, wanted %v", err, gyro.ErrStopTimeout)
+	}
+
+	close(release)
+	if err := loop.StopTimeout(time.Second); err != nil {
+		t.Fatalf("got %v once the update returned, wanted nil", err)
+	}
+
+	if err := <-result; err != nil {
+		t.Fatalf("got %v from the loop, wanted nil", err)
+	}
+}
+
+func TestAccumulator(t *testing.T) {
+	var pending, applied time.Duration
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(gyro.NewFakeClock()).
+		SetFixedTimestep(20 * time.Millisecond).
+		SetBeforeFrame(func(frame gyro.Frame) {
+			switch frame.Number {
+			case 2:
+				// Frames run 5 updates and carry nothing over, until the accumulator is set
+				if err := loop.SetAccumulator(10 * time.Millisecond); err != nil {
+					t.Errorf("failed to set the accumulator: %q", err.Error())
+				}
+				pending = loop.GetAccumulator()
+			case 4:
+				applied = loop.GetAccumulator()
+			}
+		}).
+		SetUpdateFunc(func(dt time.Duration) {})
+
+	if err := loop.RunFrames(5); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	// The set value applies to frame 3, which adds its 100ms and runs 5 updates
+	if pending != 10*time.Millisecond || applied != 10*time.Millisecond {
+		t.Fatalf("got accumulator %v after set and %v after the next frame, wanted 10ms and 10ms", pending, applied)
+	}
+
+	if err := gyro.NewLoop().SetAccumulator(time.Millisecond); !errors.Is(err, gyro.ErrNotFixedTimestep) {
+		t.Fatalf("got %v, wanted %v", err, gyro.ErrNotFixedTimestep)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	frameTimes := []time.Duration{5, 10, 16, 20, 40, 200}
+	var loop *gyro.Loop
+
+	// Uncapped frames take exactly the listed times
+	loop = gyro.NewLoop().
+		SetClock(clock).
+		SetUncapped(true).
+		SetCollectHistogram(true).
+		SetUpdateFunc(func(dt time.Duration) {
+			frame := loop.GetFrameCount()
+			clock.Advance(frameTimes[frame] * time.Millisecond)
+			if frame == uint64(len(frameTimes))-1 {
+				loop.Stop()
+			}
+		})
+
+	if err := loop.Start(); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	// The last frame time is never measured, no frame starts after it
+	histogram := loop.GetHistogram()
+	if want := []uint64{1, 1, 2, 1}; !slices.Equal(histogram.Counts, want) {
+		t.Fatalf("got counts %v, wanted %v", histogram.Counts, want)
+	}
+
+	loop.SetHistogramBuckets(50*time.Millisecond, 10*time.Millisecond)
+	if err := loop.Start(); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	histogram = loop.GetHistogram()
+	if want := []uint64{1, 4, 0}; !slices.Equal(histogram.Counts, want) || histogram.Bounds[0] != 10*time.Millisecond {
+		t.Fatalf("got bounds %v and counts %v, wanted sorted bounds and counts %v", histogram.Bounds, histogram.Counts, want)
+	}
+}
+
+func TestZeroFirstDelta(t *testing.T) {
+	for _, zero := range []bool{false, true} {
+		clock := gyro.NewFakeClock()
+		var first time.Duration
+		var loop *gyro.Loop
+
+		// The start hook takes 2s
+		loop = gyro.NewLoop().
+			SetTargetFps(10).
+			SetClock(clock).
+			SetZeroFirstDelta(zero).
+			SetOnStart(func() {
+				clock.Advance(2 * time.Second)
+			}).
+			SetUpdateFunc(func(dt time.Duration) {
+				first = dt
+				loop.Stop()
+			})
+
+		if err := loop.Start(); err != nil {
+			t.Fatalf("failed to start: %q", err.Error())
+		}
+
+		if zero && first != 0 {
+			t.Fatalf("got first delta %v with zero first delta, wanted 0", first)
+		}
+		if !zero && first != 2*time.Second {
+			t.Fatalf("got first delta %v, wanted the 2s start up time", first)
+		}
+	}
+}
+
+// goroutineID returns the id of the calling goroutine, parsed from its stack trace
+func goroutineID() string {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	return strings.Fields(string(buf))[1]
+}
+
+func TestInputOnMainThread(t *testing.T) {
+	caller := goroutineID()
+	var inputs, updates []string
+	polled := false
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(100).
+		SetClock(gyro.NewFakeClock()).
+		SetInputOnMainThread(true).
+		SetInputFunc(func() {
+			inputs = append(inputs, goroutineID())
+			polled = true
+		}).
+		SetUpdateFunc(func(dt time.Duration) {
+			// Input of the frame completed before update
+			if !polled {
+				t.Error("update ran before the input of its frame")
+			}
+			polled = false
+			updates = append(updates, goroutineID())
+		})
+
+	if err := loop.RunFrames(3); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if len(inputs) != 3 || len(updates) != 3 {
+		t.Fatalf("got %v inputs and %v updates, wanted 3 each", len(inputs), len(updates))
+	}
+
+	for i := range inputs {
+		if inputs[i] != caller || updates[i] == caller {
+			t.Fatalf("got input on goroutine %v and update on %v, wanted input on the caller %v only", inputs[i], updates[i], caller)
+		}
+	}
+
+	// A panic in input reaches the recover function and stops the loop
+	var recovered any
+	loop.SetInputFunc(func() {
+		panic("input failed")
+	}).SetRecoverFunc(func(r any) {
+		recovered = r
+	})
+
+	if err := loop.Start(); err != nil || recovered != "input failed" {
+		t.Fatalf("got %v and recovered %v, wanted the input panic recovered", err, recovered)
+	}
+}
+
+func TestOnConfigWarning(t *testing.T) {
+	var warnings []string
+	loop := gyro.NewLoop().
+		SetOnConfigWarning(func(warning string) {
+			warnings = append(warnings, warning)
+		})
+
+	// Nanosecond periods represent common rates closely enough
+	loop.SetTargetFps(144).SetTargetFpsFloat(59.94).SetRenderFps(240).SetInputFps(1000)
+	if len(warnings) != 0 {
+		t.Fatalf("got warnings %q for representable rates, wanted none", warnings)
+	}
+
+	// A rate below 1 is clamped, and a 1.67ns period truncated to 1ns
+	loop.SetTargetFpsFloat(0.5).SetTargetFpsFloat(6e8)
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "below 1") || !strings.Contains(warnings[1], "1ns") {
+		t.Fatalf("got warnings %q, wanted one for the clamped and one for the truncated rate", warnings)
+	}
+}
+
+func TestOnPresent(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	var calls []string
+	var presents []time.Duration
+	var panics []gyro.PanicInfo
+
+	loop := gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(clock).
+		SetIsolatePanics(true).
+		SetRecoverFunc(func(r any) {
+			panics = append(panics, r.(gyro.PanicInfo))
+		}).
+		SetUpdateFunc(func(dt time.Duration) {
+			calls = append(calls, "update")
+		}).
+		SetRenderFunc(func() {
+			calls = append(calls, "render")
+		}).
+		SetStatsFunc(func(stats gyro.FrameStats) {
+			presents = append(presents, stats.Present)
+		})
+
+	loop.SetOnPresent(func() {
+		calls = append(calls, "present")
+		clock.Advance(3 * time.Millisecond)
+		if loop.GetFrameCount() == 1 {
+			panic("present failed")
+		}
+	})
+
+	if err := loop.RunFrames(2); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	want := []string{"update", "render", "present", "update", "render", "present"}
+	if !slices.Equal(calls, want) {
+		t.Fatalf("got calls %v, wanted %v", calls, want)
+	}
+
+	if len(presents) != 2 || presents[0] != 3*time.Millisecond {
+		t.Fatalf("got present times %v, wanted 3ms", presents)
+	}
+
+	if len(panics) != 1 || panics[0].Phase != gyro.PHASE_PRESENT {
+		t.Fatalf("got panics %v, wanted 1 present panic", panics)
+	}
+}
+
+func TestFpsSampleFrames(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	var samples []int
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(40).
+		SetClock(clock).
+		SetFpsSampleFrames(20).
+		SetUpdateFunc(func(dt time.Duration) {
+			// Every other frame overruns to 50ms, so 20 frames take 750ms
+			if loop.GetFrameCount()%2 == 1 {
+				clock.Advance(50 * time.Millisecond)
+			}
+		}).
+		SetOnFpsSample(func(fps int) {
+			samples = append(samples, fps)
+		})
+
+	if err := loop.RunFrames(41); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	// Samples are taken every 20 frames, whatever the time they took
+	if !slices.Equal(samples, []int{27, 27}) {
+		t.Fatalf("got samples %v, wanted 2 samples of 27 fps", samples)
+	}
+
+	loop.SetFpsSampleWindow(250 * time.Millisecond)
+	if err := loop.Validate(); !errors.Is(err, gyro.ErrFpsSampleConflict) {
+		t.Fatalf("got %v, wanted ErrFpsSampleConflict", err)
+	}
+}
+
+func TestSetCapped(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	var deltas []time.Duration
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(clock).
+		SetUpdateFunc(func(dt time.Duration) {
+			deltas = append(deltas, dt)
+			clock.Advance(time.Millisecond)
+			switch loop.GetFrameCount() {
+			case 2:
+				loop.SetCapped(false)
+			case 5:
+				loop.SetCapped(true)
+			}
+		})
+
+	if err := loop.RunFrames(9); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	// The delta leaves out the 1ms of update, so uncapped frames get none.
+	// Toggling applies from the next frame, and re-capping paces the frames
+	// again without a spike.
+	want := []time.Duration{0, 99, 99, 99, 0, 0, 0, 99, 99}
+	for i := range want {
+		want[i] *= time.Millisecond
+	}
+	want[0] = deltas[0]
+	if !slices.Equal(deltas, want) {
+		t.Fatalf("got deltas %v, wanted %v", deltas, want)
+	}
+
+	if !loop.IsCapped() || loop.GetTargetFps() != 10 {
+		t.Fatalf("got capped %v at %v fps, wanted capped at 10 fps", loop.IsCapped(), loop.GetTargetFps())
+	}
+}
+
+func TestOnBeforeSleep(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	var sleeps []time.Duration
+	var frames []gyro.FrameStats
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(clock).
+		SetUpdateFunc(func(dt time.Duration) {
+			// The second frame overruns and has no time to sleep
+			if loop.GetFrameCount() == 1 {
+				clock.Advance(150 * time.Millisecond)
+			}
+		}).
+		SetStatsFunc(func(stats gyro.FrameStats) {
+			frames = append(frames, stats)
+		}).
+		SetOnBeforeSleep(func(d time.Duration) {
+			sleeps = append(sleeps, d)
+			clock.Advance(30 * time.Millisecond)
+		})
+
+	if err := loop.RunFrames(3); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	if !slices.Equal(sleeps, []time.Duration{100 * time.Millisecond}) {
+		t.Fatalf("got sleeps %v, wanted a single 100ms sleep", sleeps)
+	}
+
+	// The hook isn't part of the frame and its time is taken from the sleep
+	if frames[0].Frame != 0 || frames[0].Sleep != 100*time.Millisecond {
+		t.Fatalf("got frame %v and sleep %v, wanted 0 and 100ms", frames[0].Frame, frames[0].Sleep)
+	}
+	if loop.GetElapsed() != 250*time.Millisecond {
+		t.Fatalf("got elapsed %v, wanted 250ms", loop.GetElapsed())
+	}
+}
+
+// lateClock is a fake clock oversleeping every sleep, like time.Sleep does
+type lateClock struct {
+	*gyro.FakeClock
+	late time.Duration
+}
+
+func (c lateClock) Sleep(d time.Duration) {
+	c.Advance(d + c.late)
+}
+
+func TestDriftCorrection(t *testing.T) {
+	clock := lateClock{FakeClock: gyro.NewFakeClock(), late: time.Millisecond}
+	loop := gyro.NewLoop().
+		SetTargetFps(60).
+		SetClock(clock).
+		SetUpdateFunc(func(dt time.Duration) {})
+
+	if err := loop.RunFrames(601); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	// Sleeping 1ms late every frame would add up to 600ms, each frame
+	// instead sleeps less to stay on the schedule of the first one
+	want := 600 * loop.GetTargetPeriod()
+	if elapsed := loop.GetElapsed(); elapsed < want || elapsed > want+time.Millisecond {
+		t.Fatalf("got %v elapsed over 600 frames, wanted %v", elapsed, want)
+	}
+}
+
+func TestPausedDuration(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	var whilePaused time.Duration
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(clock).
+		SetUpdateFunc(func(dt time.Duration) {}).
+		SetAfterFrame(func(frame gyro.Frame) {
+			switch frame.Number {
+			case 1, 6:
+				loop.Pause()
+			case 3:
+				// Counted up to now while still paused
+				whilePaused = loop.GetPausedDuration()
+			case 4:
+				loop.Resume()
+			}
+		})
+
+	if err := loop.RunFrames(9); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	// Paused for 300ms from the end of frame 1 to the end of frame 4,
+	// and for 100ms from the end of frame 6 until the loop exited
+	if whilePaused != 200*time.Millisecond {
+		t.Fatalf("got %v paused while paused, wanted 200ms", whilePaused)
+	}
+	if paused := loop.GetPausedDuration(); paused != 400*time.Millisecond {
+		t.Fatalf("got %v paused, wanted 400ms", paused)
+	}
+
+	// A fresh start forgets the previous run
+	if err := loop.RunFrames(2); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+	if paused := loop.GetPausedDuration(); paused != 0 {
+		t.Fatalf("got %v paused after a restart, wanted 0", paused)
+	}
+}
+
+func TestRenderFirst(t *testing.T) {
+	var calls []string
+	var renderDeltas []time.Duration
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(gyro.NewFakeClock()).
+		SetRenderFirst(true).
+		SetInputFunc(func() {
+			calls = append(calls, "input")
+		}).
+		SetUpdateFunc(func(dt time.Duration) {
+			calls = append(calls, "update")
+		}).
+		SetLateUpdateFunc(func(dt time.Duration) {
+			calls = append(calls, "late")
+		}).
+		SetRenderFuncCtx(func(frame gyro.Frame) {
+			calls = append(calls, "render")
+			renderDeltas = append(renderDeltas, frame.Delta)
+		})
+
+	if err := loop.RunFrames(3); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	want := []string{"input", "render", "update", "late", "input", "render", "update", "late", "input", "render", "update", "late"}
+	if !slices.Equal(calls, want) {
+		t.Fatalf("got calls %v, wanted %v", calls, want)
+	}
+
+	// Render gets the delta time of the updates it shows, the first one none
+	if renderDeltas[0] != 0 || renderDeltas[2] != 100*time.Millisecond {
+		t.Fatalf("got render deltas %v, wanted 0 then the previous 100ms", renderDeltas)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	var logs bytes.Buffer
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(clock).
+		SetLogger(slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))).
+		SetIsolatePanics(true).
+		SetRecoverFunc(func(r any) {}).
+		SetUpdateFunc(func(dt time.Duration) {
+			switch loop.GetFrameCount() {
+			case 1:
+				clock.Advance(150 * time.Millisecond)
+			case 2:
+				panic("update failed")
+			}
+		})
+
+	if err := loop.RunFrames(3); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	records := decodeLogs(t, &logs)
+
+	var msgs []string
+	for _, record := range records {
+		msgs = append(msgs, record[slog.MessageKey].(string))
+	}
+	want := []string{gyro.LOG_MSG_START, gyro.LOG_MSG_OVERRUN, gyro.LOG_MSG_PANIC, gyro.LOG_MSG_STOP}
+	if !slices.Equal(msgs, want) {
+		t.Fatalf("got log messages %v, wanted %v", msgs, want)
+	}
+
+	if overrun := records[1]; overrun[slog.LevelKey] != "WARN" || overrun[gyro.LOG_KEY_OVERRUN] != float64(50*time.Millisecond) {
+		t.Fatalf("got overrun record %v, wanted a 50ms overrun warning", overrun)
+	}
+	if panicked := records[2]; panicked[gyro.LOG_KEY_PHASE] != gyro.PHASE_UPDATE || panicked[gyro.LOG_KEY_PANIC] != "update failed" {
+		t.Fatalf("got panic record %v, wanted the update panic", panicked)
+	}
+	if stop := records[3]; stop[gyro.LOG_KEY_FRAME] != float64(3) {
+		t.Fatalf("got stop record %v, wanted 3 frames", stop)
+	}
+
+	// Debug mode logs every frame too
+	logs.Reset()
+	if err := loop.SetDebug(true).RunFrames(2); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+	if frames := strings.Count(logs.String(), `"msg":"`+gyro.LOG_MSG_FRAME+`"`); frames != 2 {
+		t.Fatalf("got %v frame records, wanted 2:\n%s", frames, logs.String())
+	}
+}
+
+func TestUseMiddleware(t *testing.T) {
+	var calls []string
+	var deltas []time.Duration
+
+	// Counts the updates going through it
+	counted := 0
+	counter := func(next gyro.UpdateFunc) gyro.UpdateFunc {
+		return func(dt time.Duration) {
+			counted++
+			calls = append(calls, "counter")
+			next(dt)
+		}
+	}
+
+	// Halves the delta time and skips every other update
+	skipped := false
+	throttle := func(next gyro.UpdateFunc) gyro.UpdateFunc {
+		return func(dt time.Duration) {
+			calls = append(calls, "throttle")
+			if skipped = !skipped; skipped {
+				next(dt / 2)
+			}
+		}
+	}
+
+	loop := gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(gyro.NewFakeClock()).
+		SetUpdateFuncCtx(func(frame gyro.Frame) {
+			calls = append(calls, "update")
+			deltas = append(deltas, frame.Delta)
+		}).
+		Use(counter).
+		Use(throttle)
+
+	if err := loop.RunFrames(3); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	// The first middleware used is the outermost
+	want := []string{"counter", "throttle", "update", "counter", "throttle", "counter", "throttle", "update"}
+	if !slices.Equal(calls, want) {
+		t.Fatalf("got calls %v, wanted %v", calls, want)
+	}
+	if counted != 3 || deltas[1] != 50*time.Millisecond {
+		t.Fatalf("got %v counted and deltas %v, wanted 3 and 50ms", counted, deltas)
+	}
+
+	// Systems added after the middleware go through it too, and errors make it out
+	failed := errors.New("update failed")
+	counted = 0
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(gyro.NewFakeClock()).
+		Use(counter).
+		AddSystemCtx("physics", func(frame gyro.Frame) {}).
+		SetUpdateFuncErr(func(dt time.Duration) error {
+			return failed
+		})
+
+	if err := loop.RunFrames(3); !errors.Is(err, failed) || counted != 2 {
+		t.Fatalf("got %v after %v counted updates, wanted the update error after 2", err, counted)
+	}
+}
+
+func TestUpdateFuncDeadline(t *testing.T) {
+	clock := gyro.NewFakeClock()
+	var deadlines []time.Duration
+	var errs []error
+	var loop *gyro.Loop
+
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(clock).
+		SetUpdateFuncDeadline(func(ctx context.Context, dt time.Duration) {
+			deadline, _ := ctx.Deadline()
+			deadlines = append(deadlines, deadline.Sub(time.Unix(0, 0)))
+			if ctx.Err() != nil {
+				t.Error("got an expired context at the frame start")
+			}
+
+			// Optional work running past the budget sees the deadline
+			clock.Advance(120 * time.Millisecond)
+			errs = append(errs, ctx.Err())
+			select {
+			case <-ctx.Done():
+			default:
+				t.Error("got an open done channel past the deadline")
+			}
+		})
+
+	if err := loop.RunFrames(2); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	// The second frame starts right after the first one overran
+	want := []time.Duration{100 * time.Millisecond, 220 * time.Millisecond}
+	if !slices.Equal(deadlines, want) || !errors.Is(errs[0], context.DeadlineExceeded) {
+		t.Fatalf("got deadlines %v and errors %v, wanted %v and exceeded deadlines", deadlines, errs, want)
+	}
+
+	// With the default clock, Done closes once the budget is up
+	var waited time.Duration
+	loop = gyro.NewLoop().
+		SetTargetFps(50).
+		SetUpdateFuncDeadline(func(ctx context.Context, dt time.Duration) {
+			start := time.Now()
+			select {
+			case <-ctx.Done():
+				waited = time.Since(start)
+			case <-time.After(time.Second):
+				t.Error("done channel not closed past the deadline")
+			}
+		})
+
+	if err := loop.RunFrames(1); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+	if waited > 500*time.Millisecond {
+		t.Fatalf("waited %v for a 20ms budget", waited)
+	}
+}
+
+// decodeLogs decodes the JSON records written by a slog.JSONHandler
+func decodeLogs(t *testing.T, logs *bytes.Buffer) []map[string]any {
+	var records []map[string]any
+	decoder := json.NewDecoder(logs)
+	for decoder.More() {
+		var record map[string]any
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatalf("failed to decode the log: %v", err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLoggerPanics(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	// A panic passed to the recover decide function, which stops the loop
+	loop := gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(gyro.NewFakeClock()).
+		SetLogger(logger).
+		SetRecoverFuncDecide(func(r any) bool {
+			return false
+		}).
+		SetUpdateFunc(func(dt time.Duration) {
+			panic("decided")
+		})
+
+	if err := loop.RunFrames(3); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	records := decodeLogs(t, &logs)
+	if len(records) != 3 || records[1][slog.MessageKey] != gyro.LOG_MSG_PANIC || records[1][gyro.LOG_KEY_PANIC] != "decided" {
+		t.Fatalf("got records %v, wanted the decided panic logged", records)
+	}
+
+	// A panic ending the run is logged before the stop, which is an error
+	logs.Reset()
+	loop = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(gyro.NewFakeClock()).
+		SetLogger(logger).
+		SetRecoverFunc(func(r any) {}).
+		SetUpdateFunc(func(dt time.Duration) {
+			panic("fatal")
+		})
+
+	if err := loop.RunFrames(3); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	records = decodeLogs(t, &logs)
+	var msgs []any
+	for _, record := range records {
+		msgs = append(msgs, record[slog.MessageKey])
+	}
+	want := []any{gyro.LOG_MSG_START, gyro.LOG_MSG_PANIC, gyro.LOG_MSG_STOP}
+	if !slices.Equal(msgs, want) {
+		t.Fatalf("got log messages %v, wanted %v", msgs, want)
+	}
+	if stop := records[2]; stop[slog.LevelKey] != "ERROR" || stop[gyro.LOG_KEY_PANIC] != "fatal" {
+		t.Fatalf("got stop record %v, wanted an error with the panic", stop)
+	}
+
+	// A render panic after Stop, recovered on the way out
+	logs.Reset()
+	var shutdown *gyro.Loop
+	shutdown = gyro.NewLoop().
+		SetTargetFps(10).
+		SetClock(gyro.NewFakeClock()).
+		SetLogger(logger).
+		SetRecoverFunc(func(r any) {}).
+		SetUpdateFunc(func(dt time.Duration) {
+			shutdown.Stop()
+		}).
+		SetRenderFunc(func() {
+			panic("render after stop")
+		})
+
+	if err := shutdown.Start(); err != nil {
+		t.Fatalf("failed to start: %q", err.Error())
+	}
+
+	records = decodeLogs(t, &logs)
+	if len(records) != 3 || records[1][slog.MessageKey] != gyro.LOG_MSG_PANIC || records[1][slog.LevelKey] != "ERROR" {
+		t.Fatalf("got records %v, wanted the render panic after stop logged", records)
+	}
+	if panicked := records[1]; panicked[gyro.LOG_KEY_PANIC] != "render after stop" || panicked[gyro.LOG_KEY_PHASE] != gyro.PHASE_RENDER {
+		t.Fatalf("got panic record %v, wanted the render panic", panicked)
+	}
+}
@@ -0,0 +1,78 @@
+package gyro
+
+import (
+	"slices"
+	"time"
+)
+
+// Histogram counts frame times into buckets. Counts has one more bucket than
+// Bounds: Counts[i] holds the frames shorter than Bounds[i] and at least as long
+// as Bounds[i-1], and the last bucket the frames of Bounds[len(Bounds)-1] or longer.
+type Histogram struct {
+	Bounds []time.Duration
+	Counts []uint64
+}
+
+// Default histogram bucket bounds, splitting frame times around the 120, 60 and 30 fps periods
+var defaultHistogramBounds = []time.Duration{
+	8 * time.Millisecond,
+	16 * time.Millisecond,
+	33 * time.Millisecond,
+}
+
+// SetCollectHistogram makes the loop count the time between consecutive frame
+// starts into a histogram, see GetHistogram. Warmup frames aren't counted, and
+// the counts restart on every Start.
+func (l *Loop) SetCollectHistogram(collect bool) *Loop {
+	l.collectHistogram = collect
+	return l
+}
+
+func (l *Loop) IsCollectHistogram() bool {
+	return l.collectHistogram
+}
+
+// SetHistogramBuckets sets the bucket bounds of the frame time histogram, by
+// default 8ms, 16ms and 33ms, giving the buckets <8ms, 8-16ms, 16-33ms and >=33ms.
+// The bounds are sorted, duplicates and bounds of zero or less are dropped.
+// Setting them clears the counts.
+func (l *Loop) SetHistogramBuckets(bounds ...time.Duration) *Loop {
+	bounds = slices.DeleteFunc(slices.Clone(bounds), func(d time.Duration) bool {
+		return d <= 0
+	})
+	slices.Sort(bounds)
+	bounds = slices.Compact(bounds)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.histogram = Histogram{
+		Bounds: bounds,
+		Counts: make([]uint64, len(bounds)+1),
+	}
+	return l
+}
+
+// GetHistogram returns a copy of the frame time histogram of the current run,
+// or of the last one once the loop stopped
+func (l *Loop) GetHistogram() Histogram {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return Histogram{
+		Bounds: slices.Clone(l.histogram.Bounds),
+		Counts: slices.Clone(l.histogram.Counts),
+	}
+}
+
+// add counts d into its bucket
+func (h *Histogram) add(d time.Duration) {
+	i, _ := slices.BinarySearch(h.Bounds, d)
+	if i < len(h.Bounds) && h.Bounds[i] == d {
+		i++
+	}
+	h.Counts[i]++
+}
+
+// reset clears the counts, keeping the buckets
+func (h *Histogram) reset() {
+	clear(h.Counts)
+}
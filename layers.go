@@ -0,0 +1,58 @@
+package gyro
+
+import (
+	"slices"
+	"sort"
+)
+
+// Order of the render layer set by SetRenderFunc
+const DEFAULT_RENDER_LAYER = 0
+
+// renderLayer is a render function drawn in ascending order
+type renderLayer struct {
+	order  int
+	render RenderFunc
+}
+
+// AddRenderLayer registers a render function under an order. Layers render every
+// frame in ascending order, e.g. background, game, then HUD. Adding a layer with
+// an order already in use replaces its function. It's safe to call while the loop
+// runs, the change takes effect from the next frame on.
+func (l *Loop) AddRenderLayer(order int, render RenderFunc) *Loop {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Layers are copied on write, so a running frame keeps its own snapshot
+	layers := slices.Clone(l.layers)
+	i := sort.Search(len(layers), func(i int) bool {
+		return layers[i].order >= order
+	})
+
+	if i < len(layers) && layers[i].order == order {
+		layers[i].render = render
+	} else {
+		layers = slices.Insert(layers, i, renderLayer{order: order, render: render})
+	}
+
+	l.layers = layers
+	return l
+}
+
+// RemoveRenderLayer unregisters the render layer with the given order, if any.
+// It's safe to call while the loop runs, the change takes effect from the next frame on.
+func (l *Loop) RemoveRenderLayer(order int) *Loop {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.layers = slices.DeleteFunc(slices.Clone(l.layers), func(layer renderLayer) bool {
+		return layer.order == order
+	})
+	return l
+}
+
+// renderLayers calls every layer in order
+func renderLayers(layers []renderLayer, isolated RecoverFunc) {
+	for _, layer := range layers {
+		guard(PHASE_RENDER, isolated, layer.render)
+	}
+}
@@ -0,0 +1,134 @@
+package gyro
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Messages of the events logged with SetLogger
+const (
+	LOG_MSG_START   = "gyro: loop started"
+	LOG_MSG_STOP    = "gyro: loop stopped"
+	LOG_MSG_OVERRUN = "gyro: frame overrun"
+	LOG_MSG_PANIC   = "gyro: panic recovered"
+	LOG_MSG_FRAME   = "gyro: frame"
+)
+
+// Keys of the attributes logged with SetLogger, stable across releases
+const (
+	LOG_KEY_TARGET_FPS     = "target_fps"
+	LOG_KEY_FIXED_TIMESTEP = "fixed_timestep"
+	LOG_KEY_FRAME          = "frame"
+	LOG_KEY_ELAPSED        = "elapsed"
+	LOG_KEY_ERROR          = "error"
+	LOG_KEY_OVERRUN        = "overrun"
+	LOG_KEY_PHASE          = "phase"
+	LOG_KEY_PANIC          = "panic"
+	LOG_KEY_DELTA          = "delta"
+	LOG_KEY_INPUT          = "input"
+	LOG_KEY_UPDATE         = "update"
+	LOG_KEY_LATE_UPDATE    = "late_update"
+	LOG_KEY_RENDER         = "render"
+	LOG_KEY_PRESENT        = "present"
+	LOG_KEY_FRAME_TIME     = "frame_time"
+	LOG_KEY_SLEEP          = "sleep"
+)
+
+// SetLogger sets a structured logger the loop logs its events to: start and stop
+// at info level, or error level when stopping with an error or a panic, frame
+// overruns at warn level and recovered panics at error level, whichever recover
+// function they went to, including panics after a stop. The stop is always the
+// last record of a run, after the panic that ended it. In debug mode every frame
+// is logged at debug level too, instead of written to the debug writer. Messages
+// and attribute keys are the LOG_MSG_ and LOG_KEY_ constants. Nothing is logged,
+// and no attributes are built, without a logger, the default. Set it before Start.
+func (l *Loop) SetLogger(logger *slog.Logger) *Loop {
+	l.logger = logger
+	return l
+}
+
+func (l *Loop) GetLogger() *slog.Logger {
+	return l.logger
+}
+
+// logStart logs the start of a run and the pacing it runs at
+func (l *Loop) logStart() {
+	if l.logger == nil {
+		return
+	}
+
+	l.mu.Lock()
+	targetFps, fixedTimestep := l.targetFps, l.fixedTimestep
+	l.mu.Unlock()
+
+	l.logger.LogAttrs(context.Background(), slog.LevelInfo, LOG_MSG_START,
+		slog.Float64(LOG_KEY_TARGET_FPS, targetFps),
+		slog.Duration(LOG_KEY_FIXED_TIMESTEP, fixedTimestep))
+}
+
+// logStop logs the end of a run, with the error or the panic it stopped with if any
+func (l *Loop) logStop(err error, panicked any) {
+	if l.logger == nil {
+		return
+	}
+
+	l.mu.Lock()
+	frames, elapsed := l.frameCount, l.elapsed
+	l.mu.Unlock()
+
+	attrs := []slog.Attr{
+		slog.Uint64(LOG_KEY_FRAME, frames),
+		slog.Duration(LOG_KEY_ELAPSED, elapsed),
+	}
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.String(LOG_KEY_ERROR, err.Error()))
+	}
+	if panicked != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.Any(LOG_KEY_PANIC, panicked))
+	}
+	l.logger.LogAttrs(context.Background(), level, LOG_MSG_STOP, attrs...)
+}
+
+// logOverrun logs a frame that took longer than its budget
+func (l *Loop) logOverrun(frame uint64, over time.Duration) {
+	if l.logger == nil {
+		return
+	}
+
+	l.logger.LogAttrs(context.Background(), slog.LevelWarn, LOG_MSG_OVERRUN,
+		slog.Uint64(LOG_KEY_FRAME, frame),
+		slog.Duration(LOG_KEY_OVERRUN, over))
+}
+
+// logPanic logs a recovered panic, with the phase it happened in when isolated
+func (l *Loop) logPanic(r any) {
+	if l.logger == nil {
+		return
+	}
+
+	value, phase := r, ""
+	if info, ok := r.(PanicInfo); ok {
+		value, phase = info.Value, info.Phase
+	}
+	l.logger.LogAttrs(context.Background(), slog.LevelError, LOG_MSG_PANIC,
+		slog.String(LOG_KEY_PHASE, phase),
+		slog.Any(LOG_KEY_PANIC, value))
+}
+
+// logFrame logs the timings of a frame at debug level
+func (l *Loop) logFrame(frame uint64, stats FrameStats) {
+	l.logger.LogAttrs(context.Background(), slog.LevelDebug, LOG_MSG_FRAME,
+		slog.Uint64(LOG_KEY_FRAME, frame),
+		slog.Duration(LOG_KEY_DELTA, stats.Delta),
+		slog.Duration(LOG_KEY_INPUT, stats.Input),
+		slog.Duration(LOG_KEY_UPDATE, stats.Update),
+		slog.Duration(LOG_KEY_LATE_UPDATE, stats.LateUpdate),
+		slog.Duration(LOG_KEY_RENDER, stats.Render),
+		slog.Duration(LOG_KEY_PRESENT, stats.Present),
+		slog.Duration(LOG_KEY_FRAME_TIME, stats.Frame),
+		slog.Duration(LOG_KEY_SLEEP, stats.Sleep))
+}
@@ -0,0 +1,98 @@
+package gyro
+
+import (
+	"context"
+	"runtime"
+)
+
+// SetInputOnMainThread makes input run on the goroutine that called Start, locked
+// to its OS thread, while update and render run on a worker goroutine. It's meant
+// for event pumps that must run on the main thread, e.g. SDL, with Start called
+// from the main goroutine after runtime.LockOSThread in an init function.
+//
+// Every frame the worker hands the input poll to the Start goroutine and waits
+// until it returns, so input and update never run at the same time: the input of
+// a frame completes before its update begins, and the update completes before the
+// next input. State written by input can be read by update without extra locking.
+// Every other callback, including the start and stop hooks, runs on the worker.
+// A panic in input is raised again on the worker, like any other phase, and with
+// StartAsync the Start goroutine is the one running the loop. Set it before Start.
+func (l *Loop) SetInputOnMainThread(main bool) *Loop {
+	l.inputOnMainThread = main
+	return l
+}
+
+func (l *Loop) IsInputOnMainThread() bool {
+	return l.inputOnMainThread
+}
+
+// mainThread runs the input polls of a worker on the goroutine that started the loop
+type mainThread struct {
+	calls chan func()
+	done  chan any
+}
+
+// call runs fn on the main thread and waits for it, raising its panic again, if any
+func (m *mainThread) call(fn func()) {
+	m.calls <- fn
+	if p := <-m.done; p != nil {
+		panic(p)
+	}
+}
+
+// pollInput polls input like the package level pollInput, on the main thread.
+// The variables the poll captures escape, so it's kept apart from frame.
+func (m *mainThread) pollInput(input InputFunc, inputBool InputFuncBool, isolated RecoverFunc) bool {
+	var keepRunning bool
+	m.call(func() {
+		keepRunning = pollInput(input, inputBool, isolated)
+	})
+	return keepRunning
+}
+
+// serve runs fn, passing back nil or the value it panicked with
+func (m *mainThread) serve(fn func()) {
+	defer func() {
+		m.done <- recover()
+	}()
+	fn()
+}
+
+// runOnWorker runs the loop on a worker goroutine, and the input polls it hands
+// over on the calling goroutine until the worker exits. A panic escaping the
+// worker is raised again on the calling goroutine.
+func (l *Loop) runOnWorker(ctx context.Context) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	thread := &mainThread{
+		calls: make(chan func()),
+		done:  make(chan any),
+	}
+
+	type exit struct {
+		err   error
+		panic any
+	}
+
+	exited := make(chan exit, 1)
+	go func() {
+		var err error
+		defer func() {
+			exited <- exit{err: err, panic: recover()}
+		}()
+		err = l.run(ctx, thread)
+	}()
+
+	for {
+		select {
+		case fn := <-thread.calls:
+			thread.serve(fn)
+		case e := <-exited:
+			if e.panic != nil {
+				panic(e.panic)
+			}
+			return e.err
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package gyro
+
+import "time"
+
+// LoopMetrics is a consistent view of the loop's metrics at a single point in time
+type LoopMetrics struct {
+	CurrentFps       int
+	CurrentUpdateFps int
+	CurrentRenderFps int
+	TargetFps        float64
+	FrameCount       uint64
+	DroppedFrames    uint64
+	DroppedRenders   uint64
+	Elapsed          time.Duration
+
+	// LastFrameTime is the time spent in input, update and render on the last frame
+	LastFrameTime time.Duration
+
+	// FrameTimes summarizes the time between frame starts as of the last fps sample
+	FrameTimes FrameTimeStats
+
+	IsRunning bool
+	IsPaused  bool
+}
+
+// Snapshot returns the loop metrics read under a single lock, so unlike
+// calling the individual getters they all describe the same frame
+func (l *Loop) Snapshot() LoopMetrics {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return LoopMetrics{
+		CurrentFps:       l.fps(),
+		CurrentUpdateFps: l.currentUpdateFps,
+		CurrentRenderFps: l.currentRenderFps,
+		TargetFps:        l.targetFps,
+		FrameCount:       l.frameCount,
+		DroppedFrames:    l.droppedFrames,
+		DroppedRenders:   l.droppedRenders,
+		Elapsed:          l.elapsed,
+		LastFrameTime:    l.lastFrameTime,
+		FrameTimes:       l.frameTimeStats,
+		IsRunning:        l.isRunning,
+		IsPaused:         l.isPaused,
+	}
+}
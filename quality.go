@@ -0,0 +1,102 @@
+package gyro
+
+import "time"
+
+type QualityFunc func(tier int)
+
+// QualityStats describes how well the loop kept up over an fps sample window
+type QualityStats struct {
+	// FrameTimes summarizes the time between frame starts
+	FrameTimes FrameTimeStats
+
+	// Load is the average share of the frame budget spent in the frame phases,
+	// below 1 when there's headroom and above 1 when frames overrun
+	Load float64
+}
+
+// QualityController recommends a quality tier from the stats of the last
+// fps sample window and the current tier, higher tiers meaning higher quality
+type QualityController interface {
+	Recommend(stats QualityStats, tier int) int
+}
+
+// ThresholdQualityController raises the tier by one while the load stays below
+// RaiseBelow and lowers it by one while it's above LowerAbove, within MinTier
+// and MaxTier
+type ThresholdQualityController struct {
+	MinTier    int
+	MaxTier    int
+	RaiseBelow float64
+	LowerAbove float64
+}
+
+// NewQualityController returns a threshold controller over the given tiers,
+// raising quality below 50% load and lowering it above 90%
+func NewQualityController(minTier, maxTier int) *ThresholdQualityController {
+	return &ThresholdQualityController{
+		MinTier:    minTier,
+		MaxTier:    max(minTier, maxTier),
+		RaiseBelow: 0.5,
+		LowerAbove: 0.9,
+	}
+}
+
+func (c *ThresholdQualityController) Recommend(stats QualityStats, tier int) int {
+	switch {
+	case stats.Load > c.LowerAbove:
+		tier--
+	case stats.Load < c.RaiseBelow:
+		tier++
+	}
+	return min(max(tier, c.MinTier), c.MaxTier)
+}
+
+// SetQualityController sets the controller asked for a quality tier after every
+// fps sample, once per fps sample window. Every run starts at tier zero.
+func (l *Loop) SetQualityController(controller QualityController) *Loop {
+	l.qualityController = controller
+	return l
+}
+
+// SetOnQualityChange sets a function called with the new tier whenever the
+// quality controller recommends a different one
+func (l *Loop) SetOnQualityChange(onQualityChange QualityFunc) *Loop {
+	l.onQualityChange = onQualityChange
+	return l
+}
+
+// GetQualityTier returns the tier last recommended by the quality controller
+func (l *Loop) GetQualityTier() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.qualityTier
+}
+
+// updateQuality asks the quality controller for a tier given the last sample window
+func (l *Loop) updateQuality(times FrameTimeStats, work time.Duration, frames int, budget time.Duration) {
+	if l.qualityController == nil || frames == 0 {
+		return
+	}
+
+	stats := QualityStats{
+		FrameTimes: times,
+		Load:       float64(work) / float64(frames) / float64(budget),
+	}
+
+	l.mu.Lock()
+	tier := l.qualityTier
+	l.mu.Unlock()
+
+	recommended := l.qualityController.Recommend(stats, tier)
+	if recommended == tier {
+		return
+	}
+
+	l.mu.Lock()
+	l.qualityTier = recommended
+	l.mu.Unlock()
+
+	if l.onQualityChange != nil {
+		l.onQualityChange(recommended)
+	}
+}
@@ -0,0 +1,177 @@
+package gyro
+
+import "runtime"
+
+// renderJob holds everything a single render call needs, so it can be handed
+// to the render goroutine by value
+type renderJob struct {
+	layers      []renderLayer
+	renderAlpha RenderFuncAlpha
+	renderFrame RenderFuncCtx
+	present     HookFunc
+	frame       Frame
+	isolated    RecoverFunc
+}
+
+// run renders and then presents the frame
+func (j renderJob) run() {
+	j.render()
+	j.presentFrame()
+}
+
+// render calls the render function with the highest precedence
+func (j renderJob) render() {
+	switch {
+	case j.renderFrame != nil:
+		guard(PHASE_RENDER, j.isolated, func() {
+			j.renderFrame(j.frame)
+		})
+	case j.renderAlpha != nil:
+		guard(PHASE_RENDER, j.isolated, func() {
+			j.renderAlpha(j.frame.Alpha)
+		})
+	default:
+		renderLayers(j.layers, j.isolated)
+	}
+}
+
+// presentFrame calls the present hook, if set
+func (j renderJob) presentFrame() {
+	if j.present != nil {
+		guard(PHASE_PRESENT, j.isolated, j.present)
+	}
+}
+
+// renderer runs render jobs on its own goroutine, one at a time
+type renderer struct {
+	jobs chan renderJob
+	done chan any
+	busy bool
+}
+
+func newRenderer() *renderer {
+	r := &renderer{
+		jobs: make(chan renderJob),
+		done: make(chan any),
+	}
+
+	go func() {
+		// Graphics contexts are often bound to a thread, so keep render on one
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		for job := range r.jobs {
+			r.done <- runRenderJob(job)
+		}
+	}()
+
+	return r
+}
+
+// runRenderJob runs job and returns the value of any panic it didn't recover from
+func runRenderJob(job renderJob) (r any) {
+	defer func() {
+		r = recover()
+	}()
+
+	job.run()
+	return nil
+}
+
+// wait blocks until the render in flight, if any, completes. A panic
+// from that render is raised again on the calling goroutine.
+func (r *renderer) wait() {
+	if !r.busy {
+		return
+	}
+
+	r.busy = false
+	if p := <-r.done; p != nil {
+		panic(p)
+	}
+}
+
+// submit waits for the previous render and hands job over without waiting for it
+func (r *renderer) submit(job renderJob) {
+	r.wait()
+	r.busy = true
+	r.jobs <- job
+}
+
+// trySubmit hands job over unless the previous render is still busy,
+// in which case job is dropped and it returns false
+func (r *renderer) trySubmit(job renderJob) bool {
+	if r.busy {
+		select {
+		case p := <-r.done:
+			r.busy = false
+			if p != nil {
+				panic(p)
+			}
+		default:
+			return false
+		}
+	}
+
+	r.busy = true
+	r.jobs <- job
+	return true
+}
+
+// stop waits for the render in flight and ends the render goroutine
+func (r *renderer) stop() {
+	defer close(r.jobs)
+	r.wait()
+}
+
+// SetOnPresent sets a function called right after render every frame that
+// rendered, the point to swap front and back buffers and present the frame.
+// Like render, its panics are recovered with SetIsolatePanics, reported with
+// PHASE_PRESENT, and FrameStats.Present holds its duration. With concurrent
+// render it runs on the render goroutine after each render, and its time is
+// part of the render the next frame waits for.
+func (l *Loop) SetOnPresent(onPresent HookFunc) *Loop {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onPresent = onPresent
+	return l
+}
+
+// SetConcurrentRender makes render run on its own goroutine, overlapping with
+// the input and update of the next frame. The loop hands each render over once
+// the previous one completes, so renders never overlap each other, and it
+// waits for the last one before Start returns.
+//
+// Render may run while update mutates the game state, so render must only read
+// data that isn't touched by the next update, e.g. a copy of the state made in
+// update or late update, double-buffered between frames. Render runs on a
+// goroutine locked to its own OS thread. A panic in render is raised again on
+// the loop goroutine at the next handover. FrameStats.Render reports how long
+// the frame waited for the previous render instead of the render time.
+// Set it before Start.
+func (l *Loop) SetConcurrentRender(concurrent bool) *Loop {
+	l.concurrentRender = concurrent
+	return l
+}
+
+func (l *Loop) IsConcurrentRender() bool {
+	return l.concurrentRender
+}
+
+// SetRenderAsyncDrop makes render run on its own goroutine like SetConcurrentRender,
+// but instead of waiting for a render still busy from a previous frame, the loop
+// drops the render of the current frame, so updates never block on render.
+// Dropped renders are counted by GetDroppedRenders and flagged in FrameStats.
+//
+// The same data-safety contract as SetConcurrentRender applies: render runs
+// while later frames update, and may take several frames, so render must only
+// read data that updates don't touch, e.g. a copy handed over through a mutex
+// or channel. Set it before Start.
+func (l *Loop) SetRenderAsyncDrop(drop bool) *Loop {
+	l.renderAsyncDrop = drop
+	return l
+}
+
+func (l *Loop) IsRenderAsyncDrop() bool {
+	return l.renderAsyncDrop
+}
@@ -0,0 +1,81 @@
+package gyro
+
+import (
+	"math"
+	"time"
+)
+
+// FrameStats holds the time spent in each phase of a single frame.
+// Phases without a function set, or skipped while paused, have a zero duration.
+type FrameStats struct {
+	Input      time.Duration
+	Update     time.Duration
+	LateUpdate time.Duration
+	Render     time.Duration
+	Present    time.Duration
+
+	// Frame is the total time spent in input, update, late update, render and present
+	Frame time.Duration
+
+	// Sleep is the time the loop sleeps after the frame to keep the target fps
+	Sleep time.Duration
+
+	// Delta is the delta time handed to update, summed over every fixed update of the frame
+	Delta time.Duration
+
+	// RenderDropped is set when render was dropped because the previous render was still busy
+	RenderDropped bool
+
+	// Systems holds the update time of every system, in registration order.
+	// The slice is reused across frames, copy it to keep it past the stats call.
+	Systems []SystemStats
+}
+
+// SystemStats holds the time a system spent in update during a frame,
+// summed over every fixed update of the frame
+type SystemStats struct {
+	Name   string
+	Update time.Duration
+}
+
+// FrameTimeStats summarizes the time between consecutive frame starts over an fps sample window
+type FrameTimeStats struct {
+	Min    time.Duration
+	Max    time.Duration
+	Avg    time.Duration
+	StdDev time.Duration
+}
+
+// frameTimes accumulates frame times incrementally with Welford's algorithm
+type frameTimes struct {
+	count    int
+	min, max time.Duration
+	mean, m2 float64
+}
+
+func (f *frameTimes) add(d time.Duration) {
+	if f.count == 0 || d < f.min {
+		f.min = d
+	}
+	if f.count == 0 || d > f.max {
+		f.max = d
+	}
+
+	f.count++
+	delta := float64(d) - f.mean
+	f.mean += delta / float64(f.count)
+	f.m2 += delta * (float64(d) - f.mean)
+}
+
+func (f *frameTimes) stats() FrameTimeStats {
+	if f.count == 0 {
+		return FrameTimeStats{}
+	}
+
+	return FrameTimeStats{
+		Min:    f.min,
+		Max:    f.max,
+		Avg:    time.Duration(math.Round(f.mean)),
+		StdDev: time.Duration(math.Round(math.Sqrt(f.m2 / float64(f.count)))),
+	}
+}
@@ -0,0 +1,98 @@
+package gyro
+
+import "time"
+
+// stepRequest asks a loop in step mode to run a single frame
+type stepRequest struct {
+	deltaTime time.Duration
+
+	// Receives nil once the frame ran, or ErrNotRunning if the run ended first
+	done chan error
+}
+
+// SetStepMode stops the loop from running frames on its own, so that frames
+// only run through Step. Frame pacing and GetCurrentFps are meaningless while
+// in step mode. It's safe to call while the loop runs.
+func (l *Loop) SetStepMode(stepMode bool) *Loop {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.isStepMode && !stepMode {
+		// Wake up a loop waiting for the next step
+		select {
+		case l.wakeCh <- struct{}{}:
+		default:
+		}
+	}
+
+	l.isStepMode = stepMode
+	return l
+}
+
+func (l *Loop) IsStepMode() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.isStepMode
+}
+
+// Step runs exactly one input, update and render cycle on the loop goroutine
+// and blocks until it completes. Update receives deltaTime, or when it's zero
+// or negative, the next delta source value if set, then the fixed timestep
+// if set and the target period otherwise.
+// The loop must be running in step mode. If the run ends before the step ran,
+// e.g. because the context of StartContext was cancelled, it returns ErrNotRunning.
+func (l *Loop) Step(deltaTime time.Duration) error {
+	l.mu.Lock()
+	running, stepMode, stopCh := l.isRunning, l.isStepMode, l.stopCh
+	deltaSource, framePeriod := l.deltaSource, l.framePeriod
+	l.mu.Unlock()
+
+	if !running {
+		return ErrNotRunning
+	}
+
+	if !stepMode {
+		return ErrNotStepMode
+	}
+
+	if deltaTime <= 0 && deltaSource != nil {
+		deltaTime = deltaSource()
+	}
+
+	if deltaTime <= 0 {
+		deltaTime = framePeriod
+		if l.fixedTimestep > 0 {
+			deltaTime = l.fixedTimestep
+		}
+	}
+
+	step := stepRequest{
+		deltaTime: deltaTime,
+		done:      make(chan error, 1),
+	}
+
+	select {
+	case l.stepCh <- step:
+	case <-stopCh:
+		return ErrNotRunning
+	}
+
+	return <-step.done
+}
+
+// step runs the frame requested by Step
+func (l *Loop) step(s *runState, step stepRequest) {
+	defer func() {
+		step.done <- nil
+	}()
+
+	s.stepDelta = step.deltaTime
+	defer func() {
+		s.stepDelta = 0
+	}()
+
+	l.runFrame(s)
+	if s.renderer != nil {
+		s.renderer.wait()
+	}
+}
@@ -0,0 +1,249 @@
+package gyro
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// Name of the system registered by SetUpdateFunc
+const DEFAULT_SYSTEM = "default"
+
+// system is a named update function, taking either the delta time or the whole frame
+type system struct {
+	name           string
+	update         UpdateFunc
+	updateFrame    UpdateFuncCtx
+	updateErr      UpdateFuncErr
+	realScaled     UpdateFuncRealScaled
+	updateDeadline UpdateFuncDeadline
+
+	// Set for systems only running every few updates, see AddSystemRated
+	rate *systemRate
+
+	// Update wrapped in the middleware chain, nil without middleware,
+	// and the frame and error of the call going through it
+	wrapped UpdateFunc
+	call    *systemCall
+}
+
+// systemCall carries what the middleware chain doesn't pass down to the system
+type systemCall struct {
+	frame Frame
+	ctx   context.Context
+	err   error
+}
+
+// systemRate tracks the updates a rated system skipped since it last ran
+type systemRate struct {
+	every     int
+	skipped   int
+	delta     time.Duration
+	realDelta time.Duration
+}
+
+// AddSystem registers an update function under a name. Systems run every frame
+// in registration order, each receiving the same delta time. Adding a system with
+// a name already in use replaces its function and keeps its position. It's safe
+// to call while the loop runs, the change takes effect from the next frame on.
+func (l *Loop) AddSystem(name string, update UpdateFunc) *Loop {
+	return l.addSystem(system{name: name, update: update})
+}
+
+// AddSystemCtx behaves like AddSystem, but the system receives the whole frame
+func (l *Loop) AddSystemCtx(name string, update UpdateFuncCtx) *Loop {
+	return l.addSystem(system{name: name, updateFrame: update})
+}
+
+// AddSystemRated registers a system like AddSystem, but calls it only on every
+// nth update, e.g. a 10Hz pathfinding system every 6 updates of a 60Hz fixed
+// timestep. In fixed timestep mode every fixed step counts as an update. The
+// system receives the delta times of the n updates since it last ran summed up,
+// so it covers the same time as the systems running on every update. An every
+// below 2 runs the system on every update.
+func (l *Loop) AddSystemRated(name string, update UpdateFunc, every int) *Loop {
+	added := system{name: name, update: update}
+	if every > 1 {
+		added.rate = &systemRate{every: every}
+	}
+	return l.addSystem(added)
+}
+
+func (l *Loop) addSystem(added system) *Loop {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	added.wrap(l.middleware)
+
+	// Systems are copied on write, so a running frame keeps its own snapshot
+	systems := make([]system, 0, len(l.systems)+1)
+	replaced := false
+	for _, sys := range l.systems {
+		if sys.name == added.name {
+			sys = added
+			replaced = true
+		}
+		systems = append(systems, sys)
+	}
+
+	if !replaced {
+		systems = append(systems, added)
+	}
+
+	l.systems = systems
+	return l
+}
+
+// RemoveSystem unregisters the system with the given name, if any.
+// It's safe to call while the loop runs, the change takes effect from the next frame on.
+func (l *Loop) RemoveSystem(name string) *Loop {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.systems = slices.DeleteFunc(slices.Clone(l.systems), func(sys system) bool {
+		return sys.name == name
+	})
+	return l
+}
+
+// Use wraps a middleware around the update of every system, e.g. to time,
+// trace or throttle updates without changing them. Middleware wraps in
+// registration order, so the first one used is the outermost and sees the
+// update first. It can change the delta time it passes on, or not call next
+// at all to skip the update. The chains are built once, here and whenever a
+// system is added, never per frame. It's safe to call while the loop runs,
+// the change takes effect from the next frame on.
+func (l *Loop) Use(mw UpdateMiddleware) *Loop {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.middleware = append(slices.Clone(l.middleware), mw)
+	systems := slices.Clone(l.systems)
+	for i := range systems {
+		systems[i].wrap(l.middleware)
+	}
+	l.systems = systems
+	return l
+}
+
+// wrap builds the middleware chain around the system update
+func (sys *system) wrap(middleware []UpdateMiddleware) {
+	sys.wrapped, sys.call = nil, nil
+	if len(middleware) == 0 {
+		return
+	}
+
+	call := &systemCall{}
+	next := sys.inner(call)
+	for i := len(middleware) - 1; i >= 0; i-- {
+		next = middleware[i](next)
+	}
+	sys.wrapped, sys.call = next, call
+}
+
+// inner returns the system update as the UpdateFunc at the end of the
+// middleware chain, taking the rest of the frame from call
+func (sys *system) inner(call *systemCall) UpdateFunc {
+	update, updateFrame, updateErr, realScaled := sys.update, sys.updateFrame, sys.updateErr, sys.realScaled
+	updateDeadline := sys.updateDeadline
+	switch {
+	case updateDeadline != nil:
+		return func(dt time.Duration) {
+			updateDeadline(call.ctx, dt)
+		}
+	case updateErr != nil:
+		return func(dt time.Duration) {
+			call.err = updateErr(dt)
+		}
+	case realScaled != nil:
+		return func(dt time.Duration) {
+			realScaled(dt, call.frame.RealDelta)
+		}
+	case updateFrame != nil:
+		return func(dt time.Duration) {
+			frame := call.frame
+			frame.Delta = dt
+			updateFrame(frame)
+		}
+	default:
+		return update
+	}
+}
+
+// due counts an update towards the rate, and reports whether the system runs
+// on it, in which case the frame delta times are replaced by the summed ones
+func (r *systemRate) due(frame *Frame) bool {
+	r.skipped++
+	r.delta += frame.Delta
+	r.realDelta += frame.RealDelta
+	if r.skipped < r.every {
+		return false
+	}
+
+	frame.Delta, frame.RealDelta = r.delta, r.realDelta
+	r.skipped, r.delta, r.realDelta = 0, 0, 0
+	return true
+}
+
+// reset forgets the updates skipped by a previous run
+func (r *systemRate) reset() {
+	r.skipped, r.delta, r.realDelta = 0, 0, 0
+}
+
+// systemStats resets the per system times of the frame, reusing the slice.
+// They are only measured when a stats function is set.
+func (l *Loop) systemStats(times []SystemStats, systems []system) []SystemStats {
+	if l.statsFunc == nil {
+		return nil
+	}
+
+	times = times[:0]
+	for _, sys := range systems {
+		times = append(times, SystemStats{Name: sys.name})
+	}
+	return times
+}
+
+// updateSystems calls every due system in order with the same frame and
+// deadline context, up to the first one returning an error, which stops the loop.
+// The time spent in each system is added to times, if not nil.
+func (l *Loop) updateSystems(ctx context.Context, systems []system, frame Frame, isolated RecoverFunc, times []SystemStats) {
+	for i, sys := range systems {
+		sysFrame := frame
+		if sys.rate != nil && !sys.rate.due(&sysFrame) {
+			continue
+		}
+
+		var err error
+		var start time.Time
+		if times != nil {
+			start = l.clock.Now()
+		}
+		guard(PHASE_UPDATE, isolated, func() {
+			switch {
+			case sys.wrapped != nil:
+				sys.call.frame, sys.call.ctx, sys.call.err = sysFrame, ctx, nil
+				sys.wrapped(sysFrame.Delta)
+				err = sys.call.err
+			case sys.updateDeadline != nil:
+				sys.updateDeadline(ctx, sysFrame.Delta)
+			case sys.updateErr != nil:
+				err = sys.updateErr(sysFrame.Delta)
+			case sys.realScaled != nil:
+				sys.realScaled(sysFrame.Delta, sysFrame.RealDelta)
+			case sys.updateFrame != nil:
+				sys.updateFrame(sysFrame)
+			default:
+				sys.update(sysFrame.Delta)
+			}
+		})
+
+		if times != nil {
+			times[i].Update += l.clock.Since(start)
+		}
+
+		if err != nil {
+			l.stopWithError(err)
+			return
+		}
+	}
+}